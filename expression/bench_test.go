@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/charset"
 	"github.com/pingcap/tidb/util/chunk"
@@ -188,3 +189,38 @@ func BenchmarkScalarFunctionClone(b *testing.B) {
 	}
 	b.ReportAllocs()
 }
+
+func BenchmarkConstantToPB(b *testing.B) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	con := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(1))}
+	args := []Expression{&Column{RetType: types.NewFieldType(mysql.TypeLonglong)}}
+	for i := 0; i < 50; i++ {
+		args = append(args, con)
+	}
+	in := NewFunctionInternal(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeLonglong), args...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pc := NewPBConverter(client, sc)
+		pc.ExprToPB(in)
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkExpressionsToPB(b *testing.B) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong)}
+	conds := []Expression{
+		NewFunctionInternal(ctx, ast.EQ, types.NewFieldType(mysql.TypeLonglong), col, One.Clone()),
+		NewFunctionInternal(ctx, ast.GT, types.NewFieldType(mysql.TypeLonglong),
+			NewFunctionInternal(ctx, ast.Plus, types.NewFieldType(mysql.TypeLonglong), col, One.Clone()), Zero.Clone()),
+		NewFunctionInternal(ctx, ast.In, types.NewFieldType(mysql.TypeLonglong), col, One.Clone(), Zero.Clone()),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ExpressionsToPB(sc, conds, client)
+	}
+	b.ReportAllocs()
+}
@@ -28,6 +28,9 @@ import (
 	tipb "github.com/pingcap/tipb/go-tipb"
 )
 
+// noPbCode is the pbCode of the signatures which have no ProtoBuf signature and can't be pushed down.
+const noPbCode tipb.ScalarFuncSig = -1
+
 // baseBuiltinFunc will be contained in every struct that implement builtinFunc interface.
 type baseBuiltinFunc struct {
 	args   []Expression
@@ -49,9 +52,10 @@ func newBaseBuiltinFunc(ctx sessionctx.Context, args []Expression) baseBuiltinFu
 		panic("ctx should not be nil")
 	}
 	return baseBuiltinFunc{
-		args: args,
-		ctx:  ctx,
-		tp:   types.NewFieldType(mysql.TypeUnspecified),
+		args:   args,
+		ctx:    ctx,
+		tp:     types.NewFieldType(mysql.TypeUnspecified),
+		pbCode: noPbCode,
 	}
 }
 
@@ -151,9 +155,10 @@ func newBaseBuiltinFuncWithTp(ctx sessionctx.Context, args []Expression, retType
 		fieldType.Charset, fieldType.Collate = charset.CharsetUTF8, charset.CharsetUTF8
 	}
 	return baseBuiltinFunc{
-		args: args,
-		ctx:  ctx,
-		tp:   fieldType,
+		args:   args,
+		ctx:    ctx,
+		tp:     fieldType,
+		pbCode: noPbCode,
 	}
 }
 
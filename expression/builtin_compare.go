@@ -16,6 +16,7 @@ package expression
 import (
 	"math"
 
+	"github.com/cznic/mathutil"
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/mysql"
@@ -23,6 +24,7 @@ import (
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/types/json"
+	"github.com/pingcap/tidb/util/charset"
 	tipb "github.com/pingcap/tipb/go-tipb"
 )
 
@@ -173,6 +175,20 @@ func (c *coalesceFunctionClass) getFunction(ctx sessionctx.Context, args []Expre
 		} else {
 			// Set the field length to maxFlen for other types.
 			bf.tp.Flen = maxFlen
+			// The result is of type DATE if all the temporal arguments are DATE.
+			if resultFieldType.Tp == mysql.TypeDate {
+				bf.tp.Tp, bf.tp.Decimal = mysql.TypeDate, 0
+			}
+			if retEvalTp == types.ETString {
+				if chs, coll := aggregateCharsetAndCollation(fieldTps); chs != "" {
+					if chs == charset.CharsetBin {
+						types.SetBinChsClnFlag(bf.tp)
+					} else {
+						bf.tp.Charset, bf.tp.Collate = chs, coll
+						bf.tp.Flag &^= mysql.BinaryFlag
+					}
+				}
+			}
 		}
 	}
 
@@ -200,6 +216,36 @@ func (c *coalesceFunctionClass) getFunction(ctx sessionctx.Context, args []Expre
 	return sig, nil
 }
 
+// charsetOrder orders the charsets by the characters they can represent.
+var charsetOrder = map[string]int{
+	charset.CharsetASCII:   1,
+	charset.CharsetLatin1:  2,
+	charset.CharsetUTF8:    3,
+	charset.CharsetUTF8MB4: 4,
+	charset.CharsetBin:     5,
+}
+
+// aggregateCharsetAndCollation aggregates the charsets and collations of the string arguments like MySQL:
+// a binary string results in a binary string, and otherwise the charset which can represent the others is used.
+// It returns an empty charset if there is no string argument with a known charset.
+func aggregateCharsetAndCollation(fieldTps []*types.FieldType) (chs, coll string) {
+	for _, ft := range fieldTps {
+		if ft.EvalType() != types.ETString {
+			continue
+		}
+		if _, ok := charsetOrder[ft.Charset]; !ok {
+			continue
+		}
+		if chs == "" || charsetOrder[ft.Charset] > charsetOrder[chs] {
+			chs, coll = ft.Charset, ft.Collate
+		}
+	}
+	if chs != "" && coll == "" {
+		coll, _ = charset.GetDefaultCollation(chs)
+	}
+	return chs, coll
+}
+
 // builtinCoalesceIntSig is buitin function coalesce signature which return type int
 // See http://dev.mysql.com/doc/refman/5.7/en/comparison-operators.html#function_coalesce
 type builtinCoalesceIntSig struct {
@@ -385,6 +431,21 @@ type greatestFunctionClass struct {
 	baseFunctionClass
 }
 
+// fixFlenAndDecimalForGreatestAndLeast gets the flen and decimal of the result of GREATEST and LEAST
+// when the arguments are compared as decimal, the decimal is the maximum scale of the arguments.
+func fixFlenAndDecimalForGreatestAndLeast(args []Expression) (flen, decimal int) {
+	intLen := 0
+	for _, arg := range args {
+		argFlen, argDecimal := arg.GetType().Flen, arg.GetType().Decimal
+		if argFlen == types.UnspecifiedLength || argDecimal == types.UnspecifiedLength {
+			return mysql.MaxDecimalWidth, mysql.MaxDecimalScale
+		}
+		intLen = mathutil.Max(intLen, argFlen-argDecimal)
+		decimal = mathutil.Max(decimal, argDecimal)
+	}
+	return mathutil.Min(intLen+decimal, mysql.MaxDecimalWidth), decimal
+}
+
 func (c *greatestFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (sig builtinFunc, err error) {
 	if err = c.verifyArgs(args); err != nil {
 		return nil, errors.Trace(err)
@@ -398,7 +459,14 @@ func (c *greatestFunctionClass) getFunction(ctx sessionctx.Context, args []Expre
 	for i := range args {
 		argTps[i] = tp
 	}
+	var flen, decimal int
+	if tp == types.ETDecimal {
+		flen, decimal = fixFlenAndDecimalForGreatestAndLeast(args)
+	}
 	bf := newBaseBuiltinFuncWithTp(ctx, args, tp, argTps...)
+	if tp == types.ETDecimal {
+		bf.tp.Flen, bf.tp.Decimal = flen, decimal
+	}
 	if cmpAsDatetime {
 		tp = types.ETDatetime
 	}
@@ -592,7 +660,14 @@ func (c *leastFunctionClass) getFunction(ctx sessionctx.Context, args []Expressi
 	for i := range args {
 		argTps[i] = tp
 	}
+	var flen, decimal int
+	if tp == types.ETDecimal {
+		flen, decimal = fixFlenAndDecimalForGreatestAndLeast(args)
+	}
 	bf := newBaseBuiltinFuncWithTp(ctx, args, tp, argTps...)
+	if tp == types.ETDecimal {
+		bf.tp.Flen, bf.tp.Decimal = flen, decimal
+	}
 	if cmpAsDatetime {
 		tp = types.ETDatetime
 	}
@@ -1085,17 +1160,47 @@ func (c *compareFunctionClass) refineArgs(ctx sessionctx.Context, args []Express
 	arg1, arg1IsCon := args[1].(*Constant)
 	// int non-constant [cmp] non-int constant
 	if arg0IsInt && !arg0IsCon && !arg1IsInt && arg1IsCon {
+		if args[0].GetType().Tp == mysql.TypeYear && arg1.GetType().EvalType() == types.ETString {
+			return []Expression{args[0], convertConstantToYear(ctx, arg1, c.op)}
+		}
 		arg1 = RefineConstantArg(ctx, arg1, c.op)
 		return []Expression{args[0], arg1}
 	}
 	// non-int constant [cmp] int non-constant
 	if arg1IsInt && !arg1IsCon && !arg0IsInt && arg0IsCon {
+		if args[1].GetType().Tp == mysql.TypeYear && arg0.GetType().EvalType() == types.ETString {
+			return []Expression{convertConstantToYear(ctx, arg0, symmetricOp[c.op]), args[1]}
+		}
 		arg0 = RefineConstantArg(ctx, arg0, symmetricOp[c.op])
 		return []Expression{arg0, args[1]}
 	}
 	return args
 }
 
+// convertConstantToYear converts the string constant compared with a YEAR expression to a year like MySQL,
+// e.g. '23' is converted to 2023 instead of 23. A deferred constant is kept as it is, since its deferred
+// expression is evaluated again by every execution and converted to an integer rather than a year.
+// The constants which can't be converted to years are refined by RefineConstantArg with op like the other
+// strings compared with integers.
+func convertConstantToYear(ctx sessionctx.Context, con *Constant, op opcode.Op) *Constant {
+	if con.DeferredExpr != nil {
+		return con
+	}
+	dt, err := con.Eval(nil)
+	if err != nil || dt.IsNull() {
+		return RefineConstantArg(ctx, con, op)
+	}
+	sc := ctx.GetSessionVars().StmtCtx
+	year, err := dt.ConvertTo(sc, types.NewFieldType(mysql.TypeYear))
+	if err != nil {
+		return RefineConstantArg(ctx, con, op)
+	}
+	return &Constant{
+		Value:   types.NewIntDatum(year.GetInt64()),
+		RetType: types.NewFieldType(mysql.TypeLonglong),
+	}
+}
+
 // getFunction sets compare built-in function signatures for various types.
 func (c *compareFunctionClass) getFunction(ctx sessionctx.Context, rawArgs []Expression) (sig builtinFunc, err error) {
 	if err = c.verifyArgs(rawArgs); err != nil {
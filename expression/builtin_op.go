@@ -49,6 +49,7 @@ var (
 	_ builtinFunc = &builtinDecimalIsNullSig{}
 	_ builtinFunc = &builtinDurationIsNullSig{}
 	_ builtinFunc = &builtinIntIsNullSig{}
+	_ builtinFunc = &builtinJSONIsNullSig{}
 	_ builtinFunc = &builtinRealIsNullSig{}
 	_ builtinFunc = &builtinStringIsNullSig{}
 	_ builtinFunc = &builtinTimeIsNullSig{}
@@ -786,8 +787,6 @@ func (c *isNullFunctionClass) getFunction(ctx sessionctx.Context, args []Express
 	argTp := args[0].GetType().EvalType()
 	if argTp == types.ETTimestamp {
 		argTp = types.ETDatetime
-	} else if argTp == types.ETJson {
-		argTp = types.ETString
 	}
 	bf := newBaseBuiltinFuncWithTp(ctx, args, types.ETInt, argTp)
 	bf.tp.Flen = 1
@@ -811,6 +810,9 @@ func (c *isNullFunctionClass) getFunction(ctx sessionctx.Context, args []Express
 	case types.ETString:
 		sig = &builtinStringIsNullSig{bf}
 		sig.setPbCode(tipb.ScalarFuncSig_StringIsNull)
+	case types.ETJson:
+		sig = &builtinJSONIsNullSig{bf}
+		sig.setPbCode(tipb.ScalarFuncSig_JsonIsNull)
 	default:
 		panic("unexpected types.EvalType")
 	}
@@ -902,6 +904,21 @@ func (b *builtinStringIsNullSig) evalInt(row types.Row) (int64, bool, error) {
 	return evalIsNull(isNull, err)
 }
 
+type builtinJSONIsNullSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinJSONIsNullSig) Clone() builtinFunc {
+	newSig := &builtinJSONIsNullSig{}
+	newSig.cloneFrom(&b.baseBuiltinFunc)
+	return newSig
+}
+
+func (b *builtinJSONIsNullSig) evalInt(row types.Row) (int64, bool, error) {
+	_, isNull, err := b.args[0].EvalJSON(b.ctx, row)
+	return evalIsNull(isNull, err)
+}
+
 type builtinTimeIsNullSig struct {
 	baseBuiltinFunc
 }
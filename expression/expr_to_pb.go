@@ -14,15 +14,21 @@
 package expression
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/metrics"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/charset"
 	"github.com/pingcap/tidb/util/codec"
 	tipb "github.com/pingcap/tipb/go-tipb"
 	log "github.com/sirupsen/logrus"
@@ -30,33 +36,143 @@ import (
 
 // ExpressionsToPB converts expression to tipb.Expr.
 func ExpressionsToPB(sc *stmtctx.StatementContext, exprs []Expression, client kv.Client) (pbExpr *tipb.Expr, pushed []Expression, remained []Expression) {
-	pc := PbConverter{client: client, sc: sc}
+	pc := newPooledPBConverter(client, sc)
+	pbExpr, pushed, remained, _ = pc.expressionsToPB(exprs, false)
+	releasePBConverter(pc)
+	return
+}
+
+// ExpressionsToPBWithReasons converts expression to tipb.Expr like ExpressionsToPB,
+// reasons[i] explains why remained[i] can't be pushed down.
+func ExpressionsToPBWithReasons(sc *stmtctx.StatementContext, exprs []Expression, client kv.Client) (
+	pbExpr *tipb.Expr, pushed []Expression, remained []Expression, reasons []string) {
+	pc := newPooledPBConverter(client, sc)
+	pbExpr, pushed, remained, reasons = pc.expressionsToPB(exprs, true)
+	releasePBConverter(pc)
+	return
+}
+
+// expressionsToPB converts the CNF exprs to tipb.Expr, the reasons are only collected if withReasons is true.
+func (pc *PbConverter) expressionsToPB(exprs []Expression, withReasons bool) (
+	pbExpr *tipb.Expr, pushed []Expression, remained []Expression, reasons []string) {
+	var pbExprs []*tipb.Expr
 	for _, expr := range exprs {
-		v := pc.ExprToPB(expr)
+		var (
+			v      *tipb.Expr
+			reason string
+		)
+		if withReasons {
+			v, reason = pc.ExprToPBWithReason(expr)
+		} else {
+			v = pc.ExprToPB(expr)
+		}
+		countPushdown(expr, v != nil)
 		if v == nil {
 			remained = append(remained, expr)
+			if withReasons {
+				reasons = append(reasons, reason)
+			}
 			continue
 		}
 		pushed = append(pushed, expr)
-		if pbExpr == nil {
-			pbExpr = v
+		pbExprs = append(pbExprs, v)
+	}
+	if len(pbExprs) > 0 {
+		// Merge multiple converted pb expression into a CNF.
+		pbExpr = balancedAndPBExpr(pbExprs)
+	}
+	return
+}
+
+// balancedAndPBExpr merges the conjuncts into a balanced tree of ANDs, whose depth is logarithmic
+// to the number of the conjuncts, so the client doesn't recurse deeply to evaluate many of them.
+func balancedAndPBExpr(conjuncts []*tipb.Expr) *tipb.Expr {
+	if len(conjuncts) == 1 {
+		return conjuncts[0]
+	}
+	mid := len(conjuncts) / 2
+	return &tipb.Expr{
+		Tp:       tipb.ExprType_And,
+		Children: []*tipb.Expr{balancedAndPBExpr(conjuncts[:mid]), balancedAndPBExpr(conjuncts[mid:])},
+	}
+}
+
+// SplitRangeAndFilterPB splits the CNF conditions into the ones which can be converted to ranges over
+// the index columns idxCols, and converts the others to a coprocessor filter like ExpressionsToPB does.
+// A condition is considered range-convertible if it compares an index column with constants;
+// the ranges themselves are still built by the callers.
+func SplitRangeAndFilterPB(sc *stmtctx.StatementContext, conds []Expression, idxCols []*Column, client kv.Client) (
+	accessConds []Expression, filter *tipb.Expr, pushed []Expression, remained []Expression) {
+	filterConds := make([]Expression, 0, len(conds))
+	for _, cond := range conds {
+		if isRangeCond(cond, idxCols) {
+			accessConds = append(accessConds, cond)
 		} else {
-			// Merge multiple converted pb expression into a CNF.
-			pbExpr = &tipb.Expr{
-				Tp:       tipb.ExprType_And,
-				Children: []*tipb.Expr{pbExpr, v},
-			}
+			filterConds = append(filterConds, cond)
 		}
 	}
+	filter, pushed, remained = ExpressionsToPB(sc, filterConds, client)
 	return
 }
 
+// isRangeCond checks whether cond compares one of cols with constants.
+func isRangeCond(cond Expression, cols []*Column) bool {
+	sf, ok := cond.(*ScalarFunction)
+	if !ok {
+		return false
+	}
+	args := sf.GetArgs()
+	switch sf.FuncName.L {
+	case ast.EQ, ast.NullEQ, ast.LT, ast.LE, ast.GT, ast.GE:
+		if _, ok := args[0].(*Constant); ok {
+			return isIndexColumn(args[1], cols)
+		}
+		if _, ok := args[1].(*Constant); ok {
+			return isIndexColumn(args[0], cols)
+		}
+		return false
+	case ast.In:
+		for _, arg := range args[1:] {
+			if _, ok := arg.(*Constant); !ok {
+				return false
+			}
+		}
+		return isIndexColumn(args[0], cols)
+	case ast.IsNull:
+		return isIndexColumn(args[0], cols)
+	}
+	return false
+}
+
+func isIndexColumn(expr Expression, cols []*Column) bool {
+	for _, col := range cols {
+		if col.Equal(nil, expr) {
+			return true
+		}
+	}
+	return false
+}
+
 // ExpressionsToPBList converts expressions to tipb.Expr list for new plan.
 func ExpressionsToPBList(sc *stmtctx.StatementContext, exprs []Expression, client kv.Client) (pbExpr []*tipb.Expr) {
-	pc := PbConverter{client: client, sc: sc}
-	for _, expr := range exprs {
+	pc := newPooledPBConverter(client, sc)
+	pbExpr = pc.ExpressionsToPBList(exprs)
+	releasePBConverter(pc)
+	return
+}
+
+// ProjectionExprsToPB converts projection expressions to tipb.Expr list. pushed[i] is nil if
+// exprs[i] can't be pushed down, and the indexes of such expressions are returned in remainedIdx.
+func ProjectionExprsToPB(sc *stmtctx.StatementContext, client kv.Client, exprs []Expression) (pushed []*tipb.Expr, remainedIdx []int) {
+	pc := newPooledPBConverter(client, sc)
+	defer releasePBConverter(pc)
+	pushed = make([]*tipb.Expr, 0, len(exprs))
+	for i, expr := range exprs {
 		v := pc.ExprToPB(expr)
-		pbExpr = append(pbExpr, v)
+		if v == nil {
+			remainedIdx = append(remainedIdx, i)
+		}
+		pushed = append(pushed, v)
 	}
 	return
 }
@@ -65,38 +181,425 @@ func ExpressionsToPBList(sc *stmtctx.StatementContext, exprs []Expression, clien
 type PbConverter struct {
 	client kv.Client
 	sc     *stmtctx.StatementContext
+
+	// expandInList indicates whether to rewrite `a IN (b, c)` to `a = b OR a = c`
+	// when the client doesn't support the IN expression.
+	expandInList bool
+	// newCollationEnabled indicates whether the client compares strings with the new collation framework.
+	// If it's false, comparisons and LIKE over strings with non-binary collations are not pushed down.
+	newCollationEnabled bool
+	// pushdownWhitelist restricts the functions to be pushed down to the ones in it if it's not nil.
+	pushdownWhitelist map[string]struct{}
+	// pushdownBlacklist holds the functions which are never pushed down, even if they are in pushdownWhitelist.
+	pushdownBlacklist map[string]struct{}
+	// checkArgTypes indicates whether to refuse pushing down comparisons whose arguments
+	// are not evaluated as the same type, which produce malformed expressions for the client.
+	checkArgTypes bool
+	// refuseEnumSetResults indicates whether to refuse pushing down functions returning enums or sets,
+	// which can't be materialized correctly by some clients.
+	refuseEnumSetResults bool
+	// tiFlashProjection indicates whether the expressions are converted for projections evaluated by TiFlash,
+	// which are converted like the ones pushed to a TiFlash store.
+	tiFlashProjection bool
+	// storeType is the type of the storage engine the expressions are pushed down to, which decides
+	// the functions to be pushed down besides the checks of the client.
+	storeType kv.StoreType
+	// pushableColumns restricts the expressions to be pushed down to the ones only referencing the columns
+	// whose IDs are in it if it's not nil, e.g. to push down only the predicates over the partitioning key.
+	pushableColumns map[int64]struct{}
+	// evaluatedConsts caches the values of the evaluated constants, since a constant can be
+	// referenced many times by the expressions, e.g. by the repeated values of IN lists.
+	// It's created on the first evaluated constant unless it's taken from evaluatedConstsPool.
+	evaluatedConsts map[*Constant]types.Datum
+	// preConvert rewrites every expression before it's converted if it's not nil.
+	preConvert func(Expression) Expression
+	// rebalanceAssociative indicates whether to convert the chains of associative functions like `a + b + c + d`
+	// to balanced trees like `(a + b) + (c + d)`, which are less deep for the client to evaluate.
+	rebalanceAssociative bool
+	// traceRequestID is the ID of the request the expressions are converted for, which is recorded in tracedExprs.
+	traceRequestID string
+	// tracedExprs maps the converted expressions to traceRequestID if it's not nil. It's only used for tracing
+	// and doesn't change the converted expressions.
+	tracedExprs map[*tipb.Expr]string
+	// failReason receives the reason why an expression can't be converted if it's not nil.
+	// It's only set by ExprToPBWithReason.
+	failReason *string
 }
 
 // NewPBConverter creates a PbConverter.
 func NewPBConverter(client kv.Client, sc *stmtctx.StatementContext) PbConverter {
-	return PbConverter{client: client, sc: sc}
+	return PbConverter{
+		client:              client,
+		sc:                  sc,
+		newCollationEnabled: true,
+	}
+}
+
+// maxPooledConsts is the max number of the cached constants of a pooled converter, the caches of
+// larger sizes are not put back to evaluatedConstsPool to avoid holding the memory of huge IN lists.
+const maxPooledConsts = 1024
+
+// evaluatedConstsPool holds the caches of evaluated constants for newPooledPBConverter.
+var evaluatedConstsPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[*Constant]types.Datum)
+	},
+}
+
+// newPooledPBConverter creates a PbConverter like NewPBConverter, but takes its scratch buffers from a pool.
+// Only the buffers used during the conversion are pooled, the converted expressions never reference them,
+// so they are still valid after the converter is released by releasePBConverter.
+func newPooledPBConverter(client kv.Client, sc *stmtctx.StatementContext) PbConverter {
+	return PbConverter{
+		client:              client,
+		sc:                  sc,
+		newCollationEnabled: true,
+		evaluatedConsts:     evaluatedConstsPool.Get().(map[*Constant]types.Datum),
+	}
+}
+
+// releasePBConverter puts the scratch buffers of pc created by newPooledPBConverter back to the pool.
+// pc must not be used afterwards.
+func releasePBConverter(pc PbConverter) {
+	if len(pc.evaluatedConsts) > maxPooledConsts {
+		return
+	}
+	pc.Reset()
+	evaluatedConstsPool.Put(pc.evaluatedConsts)
+}
+
+// Reset clears the constant values cached by pc, so that pc can be reused for the expressions of
+// another statement, whose constants may be evaluated to different values. The options of pc are kept.
+func (pc *PbConverter) Reset() {
+	for con := range pc.evaluatedConsts {
+		delete(pc.evaluatedConsts, con)
+	}
+}
+
+// SetExpandInList sets whether the IN expression is expanded into an OR chain of
+// equal comparisons when it is not supported by the client.
+func (pc *PbConverter) SetExpandInList(expand bool) {
+	pc.expandInList = expand
+}
+
+// SetNewCollationEnabled sets whether the client supports the new collation framework.
+func (pc *PbConverter) SetNewCollationEnabled(enabled bool) {
+	pc.newCollationEnabled = enabled
+}
+
+// SetPushdownWhitelist restricts the pushed functions to the ones named in funcs,
+// which are still subject to the built-in checks. A nil funcs removes the restriction.
+func (pc *PbConverter) SetPushdownWhitelist(funcs map[string]struct{}) {
+	pc.pushdownWhitelist = funcs
+}
+
+// SetPushdownBlacklist prevents the functions named in funcs from being pushed down,
+// e.g. when the client's implementations of them are buggy. A nil funcs removes the restriction.
+func (pc *PbConverter) SetPushdownBlacklist(funcs map[string]struct{}) {
+	pc.pushdownBlacklist = funcs
+}
+
+// SetCheckArgTypes sets whether to check that the arguments of comparisons are of the same type.
+func (pc *PbConverter) SetCheckArgTypes(check bool) {
+	pc.checkArgTypes = check
+}
+
+// SetRefuseEnumSetResults sets whether to refuse pushing down functions returning enums or sets.
+func (pc *PbConverter) SetRefuseEnumSetResults(refuse bool) {
+	pc.refuseEnumSetResults = refuse
+}
+
+// SetTiFlashProjection sets whether the expressions are converted for projections evaluated by TiFlash.
+func (pc *PbConverter) SetTiFlashProjection(enabled bool) {
+	pc.tiFlashProjection = enabled
+}
+
+// SetStoreType sets the type of the storage engine the expressions are pushed down to, which is TiKV by default.
+func (pc *PbConverter) SetStoreType(storeType kv.StoreType) {
+	pc.storeType = storeType
+}
+
+// SetPushableColumns restricts the pushed expressions to the ones only referencing the columns whose IDs
+// are in colIDs, the expressions referencing other columns are kept in TiDB. A nil colIDs removes the restriction.
+func (pc *PbConverter) SetPushableColumns(colIDs []int64) {
+	if colIDs == nil {
+		pc.pushableColumns = nil
+		return
+	}
+	pc.pushableColumns = make(map[int64]struct{}, len(colIDs))
+	for _, id := range colIDs {
+		pc.pushableColumns[id] = struct{}{}
+	}
+}
+
+// SetPreConvert sets the function to rewrite every expression before it's converted, e.g. to substitute
+// a function with an equivalent one which can be pushed down. The rewritten expression must be equivalent
+// to the original one, which is not checked by pc. A nil rewrite removes the hook.
+func (pc *PbConverter) SetPreConvert(rewrite func(Expression) Expression) {
+	pc.preConvert = rewrite
+}
+
+// SetRebalanceAssociative sets whether the chains of associative functions are converted to balanced trees.
+// Integer and decimal arithmetic may overflow at different operands after the rebalance.
+func (pc *PbConverter) SetRebalanceAssociative(rebalance bool) {
+	pc.rebalanceAssociative = rebalance
+}
+
+// SetTraceRequestID sets the ID of the request the expressions are converted for. Every expression
+// converted by ExprToPB afterwards is mapped to the ID in the map returned by TracedExprs.
+func (pc *PbConverter) SetTraceRequestID(requestID string) {
+	pc.traceRequestID = requestID
+	pc.tracedExprs = make(map[*tipb.Expr]string)
+}
+
+// TracedExprs returns the expressions converted since SetTraceRequestID is called, mapped to the request ID.
+// It returns nil if SetTraceRequestID is not called.
+func (pc *PbConverter) TracedExprs() map[*tipb.Expr]string {
+	return pc.tracedExprs
+}
+
+// ExpressionsToPBList converts expressions to tipb.Expr list, the unconvertible ones are nil in it.
+func (pc *PbConverter) ExpressionsToPBList(exprs []Expression) (pbExpr []*tipb.Expr) {
+	for _, expr := range exprs {
+		v := pc.ExprToPB(expr)
+		countPushdown(expr, v != nil)
+		pbExpr = append(pbExpr, v)
+	}
+	return
+}
+
+// countPushdown counts expr in the pushdown metrics by its function name if it's a function.
+func countPushdown(expr Expression, pushed bool) {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok {
+		return
+	}
+	metrics.RegisterExpressionMetrics()
+	if pushed {
+		metrics.ExpressionPushdownPushedCounter.WithLabelValues(sf.FuncName.L).Inc()
+	} else {
+		metrics.ExpressionPushdownRemainedCounter.WithLabelValues(sf.FuncName.L).Inc()
+	}
 }
 
 // ExprToPB converts Expression to TiPB.
-func (pc PbConverter) ExprToPB(expr Expression) *tipb.Expr {
+func (pc *PbConverter) ExprToPB(expr Expression) *tipb.Expr {
+	pbExpr := pc.exprToPB(expr)
+	if pbExpr != nil && pc.tracedExprs != nil {
+		pc.tracedExprs[pbExpr] = pc.traceRequestID
+	}
+	return pbExpr
+}
+
+// ExprToPBWithReason converts Expression to TiPB like ExprToPB, and explains why if it can't be converted.
+func (pc *PbConverter) ExprToPBWithReason(expr Expression) (*tipb.Expr, string) {
+	var reason string
+	pc.failReason = &reason
+	pbExpr := pc.ExprToPB(expr)
+	pc.failReason = nil
+	if pbExpr != nil {
+		return pbExpr, ""
+	}
+	return nil, reason
+}
+
+// unpushable records the reason why the conversion fails and returns nil. Only the first reason is kept,
+// since the conversion stops at the first expression that can't be converted.
+func (pc *PbConverter) unpushable(format string, args ...interface{}) *tipb.Expr {
+	if pc.failReason != nil && *pc.failReason == "" {
+		*pc.failReason = fmt.Sprintf(format, args...)
+	}
+	return nil
+}
+
+func (pc *PbConverter) exprToPB(expr Expression) *tipb.Expr {
+	if pc.preConvert != nil {
+		expr = pc.preConvert(expr)
+	}
 	switch x := expr.(type) {
 	case *Constant:
 		return pc.constantToPBExpr(x)
 	case *Column:
 		return pc.columnToPBExpr(x)
+	case *CorrelatedColumn:
+		// The value of a correlated column is only known when a subquery is executed
+		// for every outer row, so it can't be pushed down.
+		log.Debugf("Can't push down correlated column %s", x)
+		return pc.unpushable("correlated column %s", x)
 	case *ScalarFunction:
 		return pc.scalarFuncToPBExpr(x)
 	}
-	return nil
+	return pc.unpushable("unsupported expression %T", expr)
 }
 
-func (pc PbConverter) constantToPBExpr(con *Constant) *tipb.Expr {
-	var (
-		tp  tipb.ExprType
-		val []byte
-		ft  = con.GetType()
-	)
+// ReferencedColumns returns the sorted and deduplicated indexes of the columns
+// referenced by expr, walking it the same way as ExprToPB.
+func (pc *PbConverter) ReferencedColumns(expr Expression) []int {
+	seen := make(map[int]struct{})
+	pc.collectColumnIndexes(expr, seen)
+	idxes := make([]int, 0, len(seen))
+	for idx := range seen {
+		idxes = append(idxes, idx)
+	}
+	sort.Ints(idxes)
+	return idxes
+}
+
+func (pc *PbConverter) collectColumnIndexes(expr Expression, seen map[int]struct{}) {
+	switch x := expr.(type) {
+	case *Column:
+		seen[x.Index] = struct{}{}
+	case *ScalarFunction:
+		for _, arg := range x.GetArgs() {
+			pc.collectColumnIndexes(arg, seen)
+		}
+	}
+}
+
+// ExprDepth returns the nesting depth of the functions in expr, walking it the same way as ExprToPB.
+// Constants and columns have a depth of 0, so a comparison between them has a depth of 1.
+func (pc *PbConverter) ExprDepth(expr Expression) int {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok {
+		return 0
+	}
+	depth := 0
+	for _, arg := range sf.GetArgs() {
+		if d := pc.ExprDepth(arg); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+// FunctionsUsed returns the sorted and deduplicated names of the functions in expr,
+// walking it the same way as ExprToPB.
+func (pc *PbConverter) FunctionsUsed(expr Expression) []string {
+	seen := make(map[string]struct{})
+	pc.collectFuncNames(expr, seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (pc *PbConverter) collectFuncNames(expr Expression, seen map[string]struct{}) {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok {
+		return
+	}
+	seen[sf.FuncName.L] = struct{}{}
+	for _, arg := range sf.GetArgs() {
+		pc.collectFuncNames(arg, seen)
+	}
+}
+
+// NegatePushable returns the negation of the predicate expr, whose NOT is pushed down to the arguments of
+// AND and OR and into the comparisons, e.g. `a = 1 AND b = 2` is negated to `a != 1 OR b != 2`.
+// It returns nil if expr is not a function or its negation can't be pushed down. expr is not modified.
+func (pc *PbConverter) NegatePushable(expr Expression) Expression {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok {
+		return nil
+	}
+	negated := PushDownNot(sf.GetCtx(), sf.Clone(), true)
+	if pc.exprToPB(negated) == nil {
+		return nil
+	}
+	return negated
+}
+
+func (pc *PbConverter) constantToPBExpr(con *Constant) *tipb.Expr {
+	pbExpr, err := pc.constantToPBExprE(con)
+	if err != nil {
+		log.Warnf("Fail to convert constant %s, err: %s", con, err.Error())
+		return pc.unpushable("fail to convert constant %s: %v", con, err)
+	}
+	return pbExpr
+}
+
+// constantToPBExprE converts con to TiPB. It returns an error if con can't be evaluated or encoded,
+// and returns nil without an error if con is valid but can't be pushed down, e.g. its kind is unsupported.
+func (pc *PbConverter) constantToPBExprE(con *Constant) (*tipb.Expr, error) {
+	ft := con.GetType()
+	d, err := pc.evalConstant(con)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if d.Kind() == types.KindMysqlTime {
+		if !pc.client.IsRequestTypeSupported(kv.ReqTypeDAG, int64(tipb.ExprType_MysqlTime)) {
+			return pc.unpushable("request type not supported by client: %s", tipb.ExprType_MysqlTime), nil
+		}
+	} else if !canDatumBeEncoded(d) {
+		return pc.unpushable("unsupported constant kind %d", d.Kind()), nil
+	}
+	val, tp, err := encodeDatum(pc.sc, d)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if tp != tipb.ExprType_MysqlTime && !pc.client.IsRequestTypeSupported(kv.ReqTypeSelect, int64(tp)) {
+		return pc.unpushable("request type not supported by client: %s", tp), nil
+	}
+	if d.Kind() == types.KindMysqlEnum || d.Kind() == types.KindMysqlSet {
+		// tipb.FieldType can't carry the enum or set elements, so such a constant
+		// is pushed down as its name with a string type instead.
+		strTp := *ft
+		strTp.Tp = mysql.TypeVarString
+		strTp.Elems = nil
+		ft = &strTp
+	}
+	return &tipb.Expr{Tp: tp, Val: val, FieldType: toPBFieldType(ft)}, nil
+}
+
+// evalConstant evaluates con, or returns its value if it has been evaluated by pc.
+func (pc *PbConverter) evalConstant(con *Constant) (types.Datum, error) {
+	if d, ok := pc.evaluatedConsts[con]; ok {
+		return d, nil
+	}
 	d, err := con.Eval(nil)
 	if err != nil {
-		log.Errorf("Fail to eval constant, err: %s", err.Error())
-		return nil
+		return d, errors.Trace(err)
+	}
+	if pc.evaluatedConsts == nil {
+		pc.evaluatedConsts = make(map[*Constant]types.Datum)
 	}
+	pc.evaluatedConsts[con] = d
+	return d, nil
+}
 
+// EncodeConstant encodes the value of con in the same way as it's pushed down,
+// and returns the encoded value and its expression type.
+func EncodeConstant(sc *stmtctx.StatementContext, con *Constant) ([]byte, tipb.ExprType, error) {
+	d, err := con.Eval(nil)
+	if err != nil {
+		return nil, tipb.ExprType_Null, errors.Trace(err)
+	}
+	if !canDatumBeEncoded(d) {
+		return nil, tipb.ExprType_Null, errors.Errorf("can't encode constant of kind %d", d.Kind())
+	}
+	return encodeDatum(sc, d)
+}
+
+// canDatumBeEncoded checks whether the kind of d can be encoded by encodeDatum.
+func canDatumBeEncoded(d types.Datum) bool {
+	switch d.Kind() {
+	case types.KindNull, types.KindInt64, types.KindUint64, types.KindString, types.KindBinaryLiteral,
+		types.KindBytes, types.KindFloat32, types.KindFloat64, types.KindMysqlDuration,
+		types.KindMysqlDecimal, types.KindMysqlTime, types.KindMysqlEnum, types.KindMysqlSet, types.KindMysqlJSON:
+		return true
+	}
+	return false
+}
+
+// encodeDatum encodes d, whose kind must be accepted by canDatumBeEncoded.
+func encodeDatum(sc *stmtctx.StatementContext, d types.Datum) ([]byte, tipb.ExprType, error) {
+	var (
+		tp  tipb.ExprType
+		val []byte
+	)
 	switch d.Kind() {
 	case types.KindNull:
 		tp = tipb.ExprType_Null
@@ -118,37 +621,39 @@ func (pc PbConverter) constantToPBExpr(con *Constant) *tipb.Expr {
 	case types.KindFloat64:
 		tp = tipb.ExprType_Float64
 		val = codec.EncodeFloat(nil, d.GetFloat64())
+	case types.KindMysqlEnum:
+		tp = tipb.ExprType_String
+		val = []byte(d.GetMysqlEnum().Name)
+	case types.KindMysqlSet:
+		tp = tipb.ExprType_String
+		val = []byte(d.GetMysqlSet().Name)
 	case types.KindMysqlDuration:
+		// A duration is an interval rather than a point in time, so unlike a timestamp it's
+		// the same in every time zone and is encoded as its nanoseconds without conversion.
 		tp = tipb.ExprType_MysqlDuration
 		val = codec.EncodeInt(nil, int64(d.GetMysqlDuration().Duration))
 	case types.KindMysqlDecimal:
 		tp = tipb.ExprType_MysqlDecimal
 		val = codec.EncodeDecimal(nil, d.GetMysqlDecimal(), d.Length(), d.Frac())
+	case types.KindMysqlJSON:
+		tp = tipb.ExprType_MysqlJson
+		j := d.GetMysqlJSON()
+		val = append([]byte{j.TypeCode}, j.Value...)
 	case types.KindMysqlTime:
-		if pc.client.IsRequestTypeSupported(kv.ReqTypeDAG, int64(tipb.ExprType_MysqlTime)) {
-			tp = tipb.ExprType_MysqlTime
-			loc := pc.sc.TimeZone
-			t := d.GetMysqlTime()
-			if t.Type == mysql.TypeTimestamp && loc != time.UTC {
-				err := t.ConvertTimeZone(loc, time.UTC)
-				terror.Log(errors.Trace(err))
-			}
-			v, err := t.ToPackedUint()
-			if err != nil {
-				log.Errorf("Fail to encode value, err: %s", err.Error())
-				return nil
-			}
-			val = codec.EncodeUint(nil, v)
-			return &tipb.Expr{Tp: tp, Val: val, FieldType: toPBFieldType(ft)}
+		tp = tipb.ExprType_MysqlTime
+		loc := sc.TimeZone
+		t := d.GetMysqlTime()
+		if t.Type == mysql.TypeTimestamp && loc != time.UTC {
+			err := t.ConvertTimeZone(loc, time.UTC)
+			terror.Log(errors.Trace(err))
 		}
-		return nil
-	default:
-		return nil
-	}
-	if !pc.client.IsRequestTypeSupported(kv.ReqTypeSelect, int64(tp)) {
-		return nil
+		v, err := t.ToPackedUint()
+		if err != nil {
+			return nil, tp, errors.Trace(err)
+		}
+		val = codec.EncodeUint(nil, v)
 	}
-	return &tipb.Expr{Tp: tp, Val: val, FieldType: toPBFieldType(ft)}
+	return val, tp, nil
 }
 
 func toPBFieldType(ft *types.FieldType) *tipb.FieldType {
@@ -170,13 +675,24 @@ func collationToProto(c string) int32 {
 	return int32(mysql.DefaultCollationID)
 }
 
-func (pc PbConverter) columnToPBExpr(column *Column) *tipb.Expr {
+func (pc *PbConverter) columnToPBExpr(column *Column) *tipb.Expr {
 	if !pc.client.IsRequestTypeSupported(kv.ReqTypeSelect, int64(tipb.ExprType_ColumnRef)) {
-		return nil
+		return pc.unpushable("request type not supported by client: %s", tipb.ExprType_ColumnRef)
+	}
+	if pc.pushableColumns != nil {
+		if _, ok := pc.pushableColumns[column.ID]; !ok {
+			return pc.unpushable("column %s not pushable", column)
+		}
 	}
 	switch column.GetType().Tp {
-	case mysql.TypeBit, mysql.TypeSet, mysql.TypeEnum, mysql.TypeGeometry, mysql.TypeUnspecified:
-		return nil
+	case mysql.TypeBit, mysql.TypeEnum, mysql.TypeGeometry, mysql.TypeUnspecified:
+		return pc.unpushable("unsupported column type %s", types.TypeStr(column.GetType().Tp))
+	case mysql.TypeSet:
+		// The set elements can't be carried in tipb.FieldType, so the client
+		// must decode set columns on its own.
+		if !pc.client.IsRequestTypeSupported(kv.ReqTypeDAG, kv.ReqSubTypeSetColumn) {
+			return pc.unpushable("set columns not supported by client")
+		}
 	}
 
 	if pc.client.IsRequestTypeSupported(kv.ReqTypeDAG, kv.ReqSubTypeBasic) {
@@ -189,7 +705,7 @@ func (pc PbConverter) columnToPBExpr(column *Column) *tipb.Expr {
 	id := column.ID
 	// Zero Column ID is not a column from table, can not support for now.
 	if id == 0 || id == -1 {
-		return nil
+		return pc.unpushable("column %s is not from a table", column)
 	}
 
 	return &tipb.Expr{
@@ -197,28 +713,55 @@ func (pc PbConverter) columnToPBExpr(column *Column) *tipb.Expr {
 		Val: codec.EncodeInt(nil, id)}
 }
 
-func (pc PbConverter) scalarFuncToPBExpr(expr *ScalarFunction) *tipb.Expr {
+func (pc *PbConverter) scalarFuncToPBExpr(expr *ScalarFunction) *tipb.Expr {
 	// check whether this function can be pushed.
 	if !pc.canFuncBePushed(expr) {
-		return nil
+		if _, ok := pc.pushdownBlacklist[expr.FuncName.L]; ok {
+			return pc.unpushable("function %s blacklisted", expr.FuncName.L)
+		}
+		return pc.unpushable("function %s not whitelisted", expr.FuncName.L)
+	}
+
+	// check whether the collations of its string arguments can be handled by the client.
+	if !pc.canCollationBePushed(expr) {
+		return pc.unpushable("collations of the arguments of %s not supported by client", expr.FuncName.L)
+	}
+
+	// check whether the types of its arguments are consistent with each other.
+	if pc.checkArgTypes && !areArgTypesConsistent(expr) {
+		return pc.unpushable("inconsistent argument types of %s", expr.FuncName.L)
+	}
+
+	// check whether its result can be materialized by the client.
+	if pc.refuseEnumSetResults && (expr.RetType.Tp == mysql.TypeEnum || expr.RetType.Tp == mysql.TypeSet) {
+		return pc.unpushable("function %s returns %s", expr.FuncName.L, types.TypeStr(expr.RetType.Tp))
 	}
 
 	// check whether this function has ProtoBuf signature.
 	pbCode := expr.Function.PbCode()
 	if pbCode < 0 {
-		return nil
+		return pc.unpushable("function %s has no pb signature", expr.FuncName.L)
 	}
 
 	// check whether all of its parameters can be pushed.
 	children := make([]*tipb.Expr, 0, len(expr.GetArgs()))
 	for _, arg := range expr.GetArgs() {
-		pbArg := pc.ExprToPB(arg)
+		pbArg := pc.exprToPB(arg)
 		if pbArg == nil {
 			return nil
 		}
 		children = append(children, pbArg)
 	}
 
+	if expr.FuncName.L == ast.In && pc.expandInList && !pc.client.IsRequestTypeSupported(kv.ReqTypeDAG, int64(tipb.ExprType_In)) {
+		return pc.inToOrPBExpr(expr, pbCode, children)
+	}
+
+	if _, ok := associativeSigs[pbCode]; ok && pc.rebalanceAssociative {
+		ft := toPBFieldType(expr.RetType)
+		return balancedPBExpr(pbCode, ft, flattenAssociative(pbCode, ft, children))
+	}
+
 	// construct expression ProtoBuf.
 	return &tipb.Expr{
 		Tp:        tipb.ExprType_ScalarFunc,
@@ -228,9 +771,90 @@ func (pc PbConverter) scalarFuncToPBExpr(expr *ScalarFunction) *tipb.Expr {
 	}
 }
 
+// inEQSigs maps the signature of IN to the signature of equal comparison with the same argument type.
+var inEQSigs = map[tipb.ScalarFuncSig]tipb.ScalarFuncSig{
+	tipb.ScalarFuncSig_InInt:      tipb.ScalarFuncSig_EQInt,
+	tipb.ScalarFuncSig_InReal:     tipb.ScalarFuncSig_EQReal,
+	tipb.ScalarFuncSig_InDecimal:  tipb.ScalarFuncSig_EQDecimal,
+	tipb.ScalarFuncSig_InString:   tipb.ScalarFuncSig_EQString,
+	tipb.ScalarFuncSig_InTime:     tipb.ScalarFuncSig_EQTime,
+	tipb.ScalarFuncSig_InDuration: tipb.ScalarFuncSig_EQDuration,
+	tipb.ScalarFuncSig_InJson:     tipb.ScalarFuncSig_EQJson,
+}
+
+// inToOrPBExpr converts `a IN (b, c, ...)` to `a = b OR a = c OR ...`, children are the converted
+// arguments of the IN function.
+func (pc *PbConverter) inToOrPBExpr(expr *ScalarFunction, pbCode tipb.ScalarFuncSig, children []*tipb.Expr) *tipb.Expr {
+	eqSig, ok := inEQSigs[pbCode]
+	if !ok {
+		return pc.unpushable("IN of signature %s can't be expanded", pbCode)
+	}
+	ft := toPBFieldType(expr.RetType)
+	var pbExpr *tipb.Expr
+	for _, item := range children[1:] {
+		eq := &tipb.Expr{
+			Tp:        tipb.ExprType_ScalarFunc,
+			Sig:       eqSig,
+			Children:  []*tipb.Expr{children[0], item},
+			FieldType: ft,
+		}
+		if pbExpr == nil {
+			pbExpr = eq
+			continue
+		}
+		pbExpr = &tipb.Expr{
+			Tp:        tipb.ExprType_ScalarFunc,
+			Sig:       tipb.ScalarFuncSig_LogicalOr,
+			Children:  []*tipb.Expr{pbExpr, eq},
+			FieldType: ft,
+		}
+	}
+	return pbExpr
+}
+
+// associativeSigs are the signatures whose operands can be regrouped without changing the result.
+// The floating point arithmetic is excluded since its rounding depends on the grouping.
+var associativeSigs = map[tipb.ScalarFuncSig]struct{}{
+	tipb.ScalarFuncSig_LogicalAnd:      {},
+	tipb.ScalarFuncSig_LogicalOr:       {},
+	tipb.ScalarFuncSig_PlusInt:         {},
+	tipb.ScalarFuncSig_PlusDecimal:     {},
+	tipb.ScalarFuncSig_MultiplyInt:     {},
+	tipb.ScalarFuncSig_MultiplyDecimal: {},
+}
+
+// flattenAssociative collects the operands of the chain of sig whose children are converted into children,
+// in the order they are evaluated. Only the functions with the same flags as ft belong to the chain,
+// since the signedness of the integer arithmetic is decided by them.
+func flattenAssociative(sig tipb.ScalarFuncSig, ft *tipb.FieldType, children []*tipb.Expr) []*tipb.Expr {
+	operands := make([]*tipb.Expr, 0, len(children))
+	for _, child := range children {
+		if child.Tp == tipb.ExprType_ScalarFunc && child.Sig == sig && child.FieldType != nil && child.FieldType.Flag == ft.Flag {
+			operands = append(operands, flattenAssociative(sig, ft, child.Children)...)
+			continue
+		}
+		operands = append(operands, child)
+	}
+	return operands
+}
+
+// balancedPBExpr builds a balanced tree of sig over operands, keeping their order.
+func balancedPBExpr(sig tipb.ScalarFuncSig, ft *tipb.FieldType, operands []*tipb.Expr) *tipb.Expr {
+	if len(operands) == 1 {
+		return operands[0]
+	}
+	mid := len(operands) / 2
+	return &tipb.Expr{
+		Tp:        tipb.ExprType_ScalarFunc,
+		Sig:       sig,
+		Children:  []*tipb.Expr{balancedPBExpr(sig, ft, operands[:mid]), balancedPBExpr(sig, ft, operands[mid:])},
+		FieldType: ft,
+	}
+}
+
 // GroupByItemToPB converts group by items to pb.
 func GroupByItemToPB(sc *stmtctx.StatementContext, client kv.Client, expr Expression) *tipb.ByItem {
-	pc := PbConverter{client: client, sc: sc}
+	pc := NewPBConverter(client, sc)
 	e := pc.ExprToPB(expr)
 	if e == nil {
 		return nil
@@ -240,7 +864,7 @@ func GroupByItemToPB(sc *stmtctx.StatementContext, client kv.Client, expr Expres
 
 // SortByItemToPB converts order by items to pb.
 func SortByItemToPB(sc *stmtctx.StatementContext, client kv.Client, expr Expression, desc bool) *tipb.ByItem {
-	pc := PbConverter{client: client, sc: sc}
+	pc := NewPBConverter(client, sc)
 	e := pc.ExprToPB(expr)
 	if e == nil {
 		return nil
@@ -248,54 +872,200 @@ func SortByItemToPB(sc *stmtctx.StatementContext, client kv.Client, expr Express
 	return &tipb.ByItem{Expr: e, Desc: desc}
 }
 
-func (pc PbConverter) canFuncBePushed(sf *ScalarFunction) bool {
-	switch sf.FuncName.L {
-	case
+// collationSensitiveFuncs are the functions whose results depend on the collations of their string arguments.
+var collationSensitiveFuncs = map[string]struct{}{
+	ast.LT:     {},
+	ast.LE:     {},
+	ast.EQ:     {},
+	ast.NE:     {},
+	ast.GE:     {},
+	ast.GT:     {},
+	ast.NullEQ: {},
+	ast.In:     {},
+	ast.Like:   {},
+}
+
+func (pc *PbConverter) canCollationBePushed(sf *ScalarFunction) bool {
+	if pc.newCollationEnabled {
+		return true
+	}
+	if _, ok := collationSensitiveFuncs[sf.FuncName.L]; !ok {
+		return true
+	}
+	for _, arg := range sf.GetArgs() {
+		ft := arg.GetType()
+		if ft.EvalType() == types.ETString && !isBinCollation(ft.Collate) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameArgTypeFuncs are the functions whose arguments are all evaluated as the type implied by their signatures.
+var sameArgTypeFuncs = map[string]struct{}{
+	ast.LT:     {},
+	ast.LE:     {},
+	ast.EQ:     {},
+	ast.NE:     {},
+	ast.GE:     {},
+	ast.GT:     {},
+	ast.NullEQ: {},
+	ast.In:     {},
+}
+
+// areArgTypesConsistent checks whether the arguments of sf are evaluated as the same type.
+// The planner casts the arguments to a common type, so a mismatch means the function is malformed.
+func areArgTypesConsistent(sf *ScalarFunction) bool {
+	if _, ok := sameArgTypeFuncs[sf.FuncName.L]; !ok {
+		return true
+	}
+	args := sf.GetArgs()
+	for _, arg := range args[1:] {
+		if arg.GetType().EvalType() != args[0].GetType().EvalType() {
+			return false
+		}
+	}
+	return true
+}
+
+// isBinCollation checks whether the collation compares strings by their bytes.
+// An empty collation is converted to the default binary collation by collationToProto.
+func isBinCollation(c string) bool {
+	return c == "" || c == charset.CollationBin || strings.HasSuffix(c, "_bin")
+}
+
+func (pc *PbConverter) canFuncBePushed(sf *ScalarFunction) bool {
+	if pc.pushdownWhitelist != nil {
+		if _, ok := pc.pushdownWhitelist[sf.FuncName.L]; !ok {
+			return false
+		}
+	}
+	if _, ok := pc.pushdownBlacklist[sf.FuncName.L]; ok {
+		return false
+	}
+	pushableFuncsMu.RLock()
+	_, ok := pushableFuncs[sf.FuncName.L]
+	pushableFuncsMu.RUnlock()
+	if ok {
+		if pc.isForTiFlash() {
+			_, unsupported := tiFlashUnsupportedFuncs[sf.FuncName.L]
+			return !unsupported
+		}
+		return true
+	}
+	if pc.isForTiFlash() {
+		_, ok := tiFlashOnlyFuncs[sf.FuncName.L]
+		return ok
+	}
+	return false
+}
+
+// isForTiFlash checks whether the expressions are evaluated by TiFlash, either in a projection
+// set by SetTiFlashProjection or in any executor of a TiFlash store set by SetStoreType.
+func (pc *PbConverter) isForTiFlash() bool {
+	return pc.tiFlashProjection || pc.storeType == kv.TiFlash
+}
+
+// tiFlashUnsupportedFuncs are the functions which can be pushed down to TiKV but not to TiFlash.
+var tiFlashUnsupportedFuncs = map[string]struct{}{
+	ast.JSONType:    {},
+	ast.JSONExtract: {},
+	ast.JSONUnquote: {},
+	ast.JSONObject:  {},
+	ast.JSONArray:   {},
+	ast.JSONMerge:   {},
+	ast.JSONSet:     {},
+	ast.JSONInsert:  {},
+	ast.JSONReplace: {},
+	ast.JSONRemove:  {},
+}
+
+var (
+	pushableFuncsMu sync.RWMutex
+	// pushableFuncs are the functions which can be pushed down to TiKV.
+	pushableFuncs = map[string]struct{}{
 		// logical functions.
-		ast.LogicAnd,
-		ast.LogicOr,
-		ast.UnaryNot,
+		ast.LogicAnd: {},
+		ast.LogicOr:  {},
+		ast.UnaryNot: {},
 
 		// compare functions.
-		ast.LT,
-		ast.LE,
-		ast.EQ,
-		ast.NE,
-		ast.GE,
-		ast.GT,
-		ast.NullEQ,
-		ast.In,
-		ast.IsNull,
-		ast.Like,
+		ast.LT:     {},
+		ast.LE:     {},
+		ast.EQ:     {},
+		ast.NE:     {},
+		ast.GE:     {},
+		ast.GT:     {},
+		ast.NullEQ: {},
+		ast.In:     {},
+		ast.IsNull: {},
+		ast.Like:   {},
 
 		// arithmetical functions.
-		ast.Plus,
-		ast.Minus,
-		ast.Mul,
-		ast.Div,
+		ast.Plus:  {},
+		ast.Minus: {},
+		ast.Mul:   {},
+		ast.Div:   {},
+
+		// bitwise functions.
+		ast.And:        {},
+		ast.Or:         {},
+		ast.Xor:        {},
+		ast.BitNeg:     {},
+		ast.LeftShift:  {},
+		ast.RightShift: {},
+
+		// math functions.
+		ast.Abs:     {},
+		ast.Ceil:    {},
+		ast.Ceiling: {},
+		ast.Floor:   {},
 
 		// control flow functions.
-		ast.Case,
-		ast.If,
-		ast.Ifnull,
-		ast.Coalesce,
+		ast.Case:     {},
+		ast.If:       {},
+		ast.Ifnull:   {},
+		ast.Coalesce: {},
+
+		// cast functions.
+		ast.Cast: {},
 
 		// json functions.
-		ast.JSONType,
-		ast.JSONExtract,
-		ast.JSONUnquote,
-		ast.JSONObject,
-		ast.JSONArray,
-		ast.JSONMerge,
-		ast.JSONSet,
-		ast.JSONInsert,
-		ast.JSONReplace,
-		ast.JSONRemove,
+		ast.JSONType:    {},
+		ast.JSONExtract: {},
+		ast.JSONUnquote: {},
+		ast.JSONObject:  {},
+		ast.JSONArray:   {},
+		ast.JSONMerge:   {},
+		ast.JSONSet:     {},
+		ast.JSONInsert:  {},
+		ast.JSONReplace: {},
+		ast.JSONRemove:  {},
 
 		// date functions.
-		ast.DateFormat:
-
-		return true
+		ast.DateFormat: {},
 	}
-	return false
+)
+
+// RegisterPushableFunc makes the function named name pushable to TiKV. The function is still
+// not pushed down if its signature has no pb code.
+func RegisterPushableFunc(name string) {
+	pushableFuncsMu.Lock()
+	pushableFuncs[strings.ToLower(name)] = struct{}{}
+	pushableFuncsMu.Unlock()
+}
+
+// UnregisterPushableFunc stops pushing down the function named name to TiKV.
+func UnregisterPushableFunc(name string) {
+	pushableFuncsMu.Lock()
+	delete(pushableFuncs, strings.ToLower(name))
+	pushableFuncsMu.Unlock()
+}
+
+// tiFlashOnlyFuncs are the functions which can be pushed down to TiFlash but not to TiKV.
+var tiFlashOnlyFuncs = map[string]struct{}{
+	ast.UnaryMinus: {},
+	ast.IsTruth:    {},
+	ast.IsFalsity:  {},
+	ast.LogicXor:   {},
 }
@@ -15,14 +15,23 @@ package expression
 
 import (
 	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/metrics"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/charset"
+	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/mock"
+	tipb "github.com/pingcap/tipb/go-tipb"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type dataGen4Expr2PbTest struct {
@@ -36,6 +45,19 @@ func (dg *dataGen4Expr2PbTest) genColumn(tp byte, id int64) *Column {
 	}
 }
 
+// capabilityClient is a mock client whose support for some expression types can be overridden.
+type capabilityClient struct {
+	mock.Client
+	supported map[tipb.ExprType]bool
+}
+
+func (c *capabilityClient) IsRequestTypeSupported(reqType, subType int64) bool {
+	if supported, ok := c.supported[tipb.ExprType(subType)]; ok {
+		return supported
+	}
+	return c.Client.IsRequestTypeSupported(reqType, subType)
+}
+
 func (s *testEvaluatorSuite) TestConstant2Pb(c *C) {
 	c.Skip("constant pb has changed")
 	var constExprs []Expression
@@ -173,7 +195,7 @@ func (s *testEvaluatorSuite) TestColumn2Pb(c *C) {
 	c.Assert(len(remained), Equals, 0)
 	js, err := json.Marshal(pbExpr)
 	c.Assert(err, IsNil)
-	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":1,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":2,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAM=\",\"sig\":0,\"field_type\":{\"tp\":3,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAQ=\",\"sig\":0,\"field_type\":{\"tp\":4,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAU=\",\"sig\":0,\"field_type\":{\"tp\":5,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAY=\",\"sig\":0,\"field_type\":{\"tp\":6,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAc=\",\"sig\":0,\"field_type\":{\"tp\":7,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAg=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAk=\",\"sig\":0,\"field_type\":{\"tp\":9,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAo=\",\"sig\":0,\"field_type\":{\"tp\":10,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAs=\",\"sig\":0,\"field_type\":{\"tp\":11,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAw=\",\"sig\":0,\"field_type\":{\"tp\":12,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAA0=\",\"sig\":0,\"field_type\":{\"tp\":13,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAA8=\",\"sig\":0,\"field_type\":{\"tp\":15,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABA=\",\"sig\":0,\"field_type\":{\"tp\":245,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABE=\",\"sig\":0,\"field_type\":{\"tp\":246,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABI=\",\"sig\":0,\"field_type\":{\"tp\":249,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABM=\",\"sig\":0,\"field_type\":{\"tp\":250,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABQ=\",\"sig\":0,\"field_type\":{\"tp\":251,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABU=\",\"sig\":0,\"field_type\":{\"tp\":252,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABY=\",\"sig\":0,\"field_type\":{\"tp\":253,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAABc=\",\"sig\":0,\"field_type\":{\"tp\":254,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}")
+	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":1,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":2,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAM=\",\"sig\":0,\"field_type\":{\"tp\":3,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAQ=\",\"sig\":0,\"field_type\":{\"tp\":4,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAU=\",\"sig\":0,\"field_type\":{\"tp\":5,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAY=\",\"sig\":0,\"field_type\":{\"tp\":6,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAc=\",\"sig\":0,\"field_type\":{\"tp\":7,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAg=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAk=\",\"sig\":0,\"field_type\":{\"tp\":9,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAo=\",\"sig\":0,\"field_type\":{\"tp\":10,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAs=\",\"sig\":0,\"field_type\":{\"tp\":11,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAw=\",\"sig\":0,\"field_type\":{\"tp\":12,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAA0=\",\"sig\":0,\"field_type\":{\"tp\":13,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAA8=\",\"sig\":0,\"field_type\":{\"tp\":15,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAABA=\",\"sig\":0,\"field_type\":{\"tp\":245,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAABE=\",\"sig\":0,\"field_type\":{\"tp\":246,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAABI=\",\"sig\":0,\"field_type\":{\"tp\":249,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAABM=\",\"sig\":0,\"field_type\":{\"tp\":250,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAABQ=\",\"sig\":0,\"field_type\":{\"tp\":251,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAABU=\",\"sig\":0,\"field_type\":{\"tp\":252,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAABY=\",\"sig\":0,\"field_type\":{\"tp\":253,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAABc=\",\"sig\":0,\"field_type\":{\"tp\":254,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0}")
 	pbExprs = ExpressionsToPBList(sc, colExprs, client)
 	jsons := []string{
 		"{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":1,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}",
@@ -214,7 +236,7 @@ func (s *testEvaluatorSuite) TestColumn2Pb(c *C) {
 	c.Assert(pbExpr, NotNil)
 	js, err = json.Marshal(pbExpr)
 	c.Assert(err, IsNil)
-	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":1,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":2,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":3,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":4,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":5,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":6,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":7,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":9,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":10,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":11,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":12,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":13,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":15,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":245,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":246,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":249,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":250,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":251,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":252,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":253,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":254,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}")
+	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":1,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":2,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":3,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":4,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":5,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":6,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":7,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":9,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":10,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":11,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":12,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":13,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":15,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":245,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":246,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":249,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":250,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":251,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":252,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":2301,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":253,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAA=\",\"sig\":0,\"field_type\":{\"tp\":254,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0}],\"sig\":0}")
 	c.Assert(len(pushed), Equals, len(colExprs))
 	c.Assert(len(remained), Equals, 0)
 }
@@ -238,7 +260,7 @@ func (s *testEvaluatorSuite) TestCompareFunc2Pb(c *C) {
 	c.Assert(len(remained), Equals, 0)
 	js, err := json.Marshal(pbExpr)
 	c.Assert(err, IsNil)
-	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":100,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":110,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":120,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":130,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":140,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":150,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":160,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0}")
+	c.Assert(string(js), Equals, "{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":100,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}},{\"tp\":2301,\"children\":[{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":110,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":120,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":2301,\"children\":[{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":130,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":140,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0},{\"tp\":2301,\"children\":[{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":150,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}},{\"tp\":10000,\"children\":[{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},{\"tp\":201,\"val\":\"gAAAAAAAAAI=\",\"sig\":0,\"field_type\":{\"tp\":8,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}}],\"sig\":160,\"field_type\":{\"tp\":8,\"flag\":128,\"flen\":1,\"decimal\":0,\"collate\":63,\"charset\":\"binary\"}}],\"sig\":0}],\"sig\":0}],\"sig\":0}")
 
 	pbExprs := ExpressionsToPBList(sc, compareExprs, client)
 	c.Assert(len(pbExprs), Equals, len(compareExprs))
@@ -283,11 +305,16 @@ func (s *testEvaluatorSuite) TestLikeFunc2Pb(c *C) {
 	c.Assert(err, IsNil)
 	likeFuncs = append(likeFuncs, fc)
 
+	// The escape argument is cast to int, which is pushed down with the rest.
+	jsons := []string{
+		`{"tp":10000,"children":[{"tp":5,"val":"c3RyaW5n","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}},{"tp":5,"val":"cGF0dGVybg==","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}},{"tp":10000,"children":[{"tp":5,"val":"XA==","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}}],"sig":30,"field_type":{"tp":8,"flag":128,"flen":-1,"decimal":0,"collate":63,"charset":"binary"}}],"sig":4310,"field_type":{"tp":8,"flag":128,"flen":1,"decimal":0,"collate":63,"charset":"binary"}}`,
+		`{"tp":10000,"children":[{"tp":5,"val":"c3RyaW5n","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}},{"tp":5,"val":"JWFiYyU=","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}},{"tp":10000,"children":[{"tp":5,"val":"XA==","sig":0,"field_type":{"tp":254,"flag":0,"flen":-1,"decimal":-1,"collate":83,"charset":"utf8"}}],"sig":30,"field_type":{"tp":8,"flag":128,"flen":-1,"decimal":0,"collate":63,"charset":"binary"}}],"sig":4310,"field_type":{"tp":8,"flag":128,"flen":1,"decimal":0,"collate":63,"charset":"binary"}}`,
+	}
 	pbExprs := ExpressionsToPBList(sc, likeFuncs, client)
-	for _, pbExpr := range pbExprs {
+	for i, pbExpr := range pbExprs {
 		js, err := json.Marshal(pbExpr)
 		c.Assert(err, IsNil)
-		c.Assert(string(js), Equals, "null")
+		c.Assert(string(js), Equals, jsons[i])
 	}
 }
 
@@ -407,11 +434,38 @@ func (s *testEvaluatorSuite) TestBitwiseFunc2Pb(c *C) {
 	}
 
 	pbExprs := ExpressionsToPBList(sc, bitwiseFuncs, client)
-	for _, pbExpr := range pbExprs {
-		js, err := json.Marshal(pbExpr)
-		c.Assert(err, IsNil)
-		c.Assert(string(js), Equals, "null")
+	sigs := []tipb.ScalarFuncSig{tipb.ScalarFuncSig_BitAndSig, tipb.ScalarFuncSig_BitOrSig, tipb.ScalarFuncSig_BitXorSig, -1, -1, tipb.ScalarFuncSig_BitNegSig}
+	for i, pbExpr := range pbExprs {
+		// The shifts have no signatures and can't be pushed down.
+		if sigs[i] < 0 {
+			c.Assert(pbExpr, IsNil, Commentf("%s", funcNames[i]))
+			continue
+		}
+		c.Assert(pbExpr, NotNil, Commentf("%s", funcNames[i]))
+		c.Assert(pbExpr.Sig, Equals, sigs[i])
+		c.Assert(pbExpr.FieldType.Tp, Equals, int32(mysql.TypeLonglong))
+		c.Assert(mysql.HasUnsignedFlag(uint(pbExpr.FieldType.Flag)), IsTrue)
 	}
+
+	// signedCol & unsignedCol and ~signedCol are unsigned like MySQL.
+	signedCol := dg.genColumn(mysql.TypeLonglong, 0)
+	unsignedCol := dg.genColumn(mysql.TypeLonglong, 1)
+	unsignedCol.RetType.Flag |= mysql.UnsignedFlag
+	fieldTps := []*types.FieldType{signedCol.RetType, unsignedCol.RetType}
+	row := types.DatumRow{types.NewIntDatum(-1), types.NewUintDatum(math.MaxUint64 - 1)}
+	and, err := NewFunction(mock.NewContext(), ast.And, types.NewFieldType(mysql.TypeUnspecified), signedCol, unsignedCol)
+	c.Assert(err, IsNil)
+	neg, err := NewFunction(mock.NewContext(), ast.BitNeg, types.NewFieldType(mysql.TypeUnspecified), signedCol)
+	c.Assert(err, IsNil)
+	pbExprs = ExpressionsToPBList(sc, []Expression{and, neg}, client)
+	c.Assert(pbExprs[0], NotNil)
+	c.Assert(mysql.HasUnsignedFlag(uint(pbExprs[0].FieldType.Flag)), IsTrue)
+	d := evalPushedExpr(c, sc, pbExprs[0], fieldTps, row)
+	c.Assert(d.GetUint64(), Equals, uint64(math.MaxUint64-1))
+	c.Assert(pbExprs[1], NotNil)
+	c.Assert(mysql.HasUnsignedFlag(uint(pbExprs[1].FieldType.Flag)), IsTrue)
+	d = evalPushedExpr(c, sc, pbExprs[1], fieldTps, types.DatumRow{types.NewIntDatum(0), types.NewUintDatum(0)})
+	c.Assert(d.GetUint64(), Equals, uint64(math.MaxUint64))
 }
 
 func (s *testEvaluatorSuite) TestControlFunc2Pb(c *C) {
@@ -524,3 +578,2590 @@ func (s *testEvaluatorSuite) TestSortByItem2Pb(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(string(js), Equals, "{\"expr\":{\"tp\":201,\"val\":\"gAAAAAAAAAE=\",\"sig\":0,\"field_type\":{\"tp\":5,\"flag\":0,\"flen\":-1,\"decimal\":-1,\"collate\":83,\"charset\":\"\"}},\"desc\":true}")
 }
+
+func (s *testEvaluatorSuite) TestInExpandToOr2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := &capabilityClient{supported: map[tipb.ExprType]bool{tipb.ExprType_In: false}}
+	dg := new(dataGen4Expr2PbTest)
+
+	fc, err := NewFunction(
+		mock.NewContext(),
+		ast.In,
+		types.NewFieldType(mysql.TypeUnspecified),
+		dg.genColumn(mysql.TypeLonglong, 1),
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(1))},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(2))},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(3))},
+	)
+	c.Assert(err, IsNil)
+
+	// IN is pushed as is by default.
+	pc := NewPBConverter(client, sc)
+	pbExpr := pc.ExprToPB(fc)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_InInt)
+	c.Assert(pbExpr.Children, HasLen, 4)
+
+	pc.SetExpandInList(true)
+	pbExpr = pc.ExprToPB(fc)
+	c.Assert(pbExpr, NotNil)
+	// ((a = 1 OR a = 2) OR a = 3)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LogicalOr)
+	c.Assert(pbExpr.Children, HasLen, 2)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_LogicalOr)
+	var eqExprs []*tipb.Expr
+	eqExprs = append(eqExprs, pbExpr.Children[0].Children...)
+	eqExprs = append(eqExprs, pbExpr.Children[1])
+	c.Assert(eqExprs, HasLen, 3)
+	for i, eq := range eqExprs {
+		c.Assert(eq.Sig, Equals, tipb.ScalarFuncSig_EQInt)
+		c.Assert(eq.Children, HasLen, 2)
+		c.Assert(eq.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+		c.Assert(eq.Children[1].Tp, Equals, tipb.ExprType_Int64)
+		js, err := json.Marshal(eq.Children[1])
+		c.Assert(err, IsNil)
+		expected, err := json.Marshal(pc.ExprToPB(fc.(*ScalarFunction).GetArgs()[i+1]))
+		c.Assert(err, IsNil)
+		c.Assert(string(js), Equals, string(expected))
+	}
+
+	// IN is not expanded when the client supports it.
+	client.supported[tipb.ExprType_In] = true
+	pbExpr = pc.ExprToPB(fc)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_InInt)
+}
+
+func (s *testEvaluatorSuite) TestJSONExtractMultiPath2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	args := []Expression{dg.genColumn(mysql.TypeJSON, 1)}
+	for _, path := range []string{"$.a", "$.b", "$.c"} {
+		args = append(args, &Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum(path)})
+	}
+	fc, err := NewFunction(mock.NewContext(), ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), args...)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_JsonExtractSig)
+	c.Assert(pbExpr.FieldType.Tp, Equals, int32(mysql.TypeJSON))
+	c.Assert(pbExpr.Children, HasLen, 4)
+	c.Assert(pbExpr.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	for i, path := range []string{"$.a", "$.b", "$.c"} {
+		c.Assert(pbExpr.Children[i+1].Tp, Equals, tipb.ExprType_String)
+		c.Assert(string(pbExpr.Children[i+1].Val), Equals, path)
+	}
+}
+
+func (s *testEvaluatorSuite) TestJSONSetMultiPair2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	// JSON_SET(doc, '$.a', intCol, '$.b', 2)
+	args := []Expression{
+		dg.genColumn(mysql.TypeJSON, 1),
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.a")},
+		dg.genColumn(mysql.TypeLonglong, 2),
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.b")},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(2)},
+	}
+	fc, err := NewFunction(mock.NewContext(), ast.JSONSet, types.NewFieldType(mysql.TypeUnspecified), args...)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_JsonSetSig)
+	c.Assert(pbExpr.FieldType.Tp, Equals, int32(mysql.TypeJSON))
+	c.Assert(pbExpr.Children, HasLen, 5)
+	c.Assert(pbExpr.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	for i, path := range []string{"$.a", "$.b"} {
+		c.Assert(pbExpr.Children[2*i+1].Tp, Equals, tipb.ExprType_String)
+		c.Assert(string(pbExpr.Children[2*i+1].Val), Equals, path)
+	}
+	// The values are cast to JSON, and the constant one is folded.
+	c.Assert(pbExpr.Children[2].Sig, Equals, tipb.ScalarFuncSig_CastIntAsJson)
+	c.Assert(pbExpr.Children[4].Tp, Equals, tipb.ExprType_MysqlJson)
+
+	expr, err := PBToExpr(pbExpr, []*types.FieldType{args[0].GetType(), args[0].GetType(), args[2].GetType()}, sc)
+	c.Assert(err, IsNil)
+	c.Assert(expr.(*ScalarFunction).GetArgs()[4].(*Constant).Value.GetMysqlJSON().String(), Equals, "2")
+}
+
+func (s *testEvaluatorSuite) TestProjectionExprs2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	mod, err := NewFunction(mock.NewContext(), ast.Mod, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	exprs := []Expression{
+		dg.genColumn(mysql.TypeLonglong, 0),
+		mod,
+		plus,
+		dg.genColumn(mysql.TypeBit, 3),
+	}
+
+	pushed, remainedIdx := ProjectionExprsToPB(sc, client, exprs)
+	c.Assert(pushed, HasLen, len(exprs))
+	c.Assert(remainedIdx, DeepEquals, []int{1, 3})
+	c.Assert(pushed[0], NotNil)
+	c.Assert(pushed[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pushed[1], IsNil)
+	c.Assert(pushed[2], NotNil)
+	c.Assert(pushed[2].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+	c.Assert(pushed[3], IsNil)
+
+	pushed, remainedIdx = ProjectionExprsToPB(sc, client, exprs[2:3])
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remainedIdx, HasLen, 0)
+}
+
+func (s *testEvaluatorSuite) TestNestedCaseWhen2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	ctx := mock.NewContext()
+
+	// CASE WHEN a THEN (CASE WHEN b THEN c ELSE d END) ELSE e END
+	inner, err := NewFunction(ctx, ast.Case, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLong, 2), dg.genColumn(mysql.TypeLong, 3), dg.genColumn(mysql.TypeLong, 4))
+	c.Assert(err, IsNil)
+	outer, err := NewFunction(ctx, ast.Case, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLong, 1), inner, dg.genColumn(mysql.TypeLong, 5))
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{outer}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_CaseWhenInt)
+	c.Assert(pbExpr.Children, HasLen, 3)
+	c.Assert(pbExpr.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pbExpr.Children[2].Tp, Equals, tipb.ExprType_ColumnRef)
+	innerPb := pbExpr.Children[1]
+	c.Assert(innerPb.Tp, Equals, tipb.ExprType_ScalarFunc)
+	c.Assert(innerPb.Sig, Equals, tipb.ScalarFuncSig_CaseWhenInt)
+	c.Assert(innerPb.Children, HasLen, 3)
+	for _, child := range innerPb.Children {
+		c.Assert(child.Tp, Equals, tipb.ExprType_ColumnRef)
+	}
+
+	// The whole tree stays in TiDB if any nested branch can't be pushed.
+	inner, err = NewFunction(ctx, ast.Case, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLong, 2), dg.genColumn(mysql.TypeBit, 3), dg.genColumn(mysql.TypeLong, 4))
+	c.Assert(err, IsNil)
+	outer, err = NewFunction(ctx, ast.Case, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLong, 1), inner, dg.genColumn(mysql.TypeLong, 5))
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained = ExpressionsToPB(sc, []Expression{outer}, client)
+	c.Assert(pbExpr, IsNil)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+}
+
+func (s *testEvaluatorSuite) TestInlinedGeneratedColumn2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	ctx := mock.NewContext()
+
+	// `g > 5` where g is a generated column `g AS (a + b)` is inlined as `a + b > 5`.
+	plus, err := NewFunction(ctx, ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	five := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(5))}
+	gt, err := NewFunction(ctx, ast.GT, types.NewFieldType(mysql.TypeUnspecified), plus, five)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{gt}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_GTInt)
+	c.Assert(pbExpr.Children, HasLen, 2)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+	c.Assert(pbExpr.Children[0].Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pbExpr.Children[0].Children[1].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Int64)
+}
+
+func (s *testEvaluatorSuite) TestCollationUnaware2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+
+	genStrColumn := func(collate string, id int64) *Column {
+		tp := types.NewFieldType(mysql.TypeVarchar)
+		tp.Charset = charset.CharsetUTF8
+		tp.Collate = collate
+		return &Column{RetType: tp, ID: id, Index: int(id)}
+	}
+	ciEQ, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), genStrColumn("utf8_general_ci", 1), genStrColumn("utf8_general_ci", 2))
+	c.Assert(err, IsNil)
+	ciLike, err := NewFunction(ctx, ast.Like, types.NewFieldType(mysql.TypeUnspecified), genStrColumn("utf8_general_ci", 1),
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("a%")},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64('\\'))})
+	c.Assert(err, IsNil)
+	binEQ, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), genStrColumn(charset.CollationUTF8, 1), genStrColumn(charset.CollationUTF8, 2))
+	c.Assert(err, IsNil)
+	exprs := []Expression{ciEQ, ciLike, binEQ}
+
+	pc := NewPBConverter(client, sc)
+	for _, expr := range exprs {
+		c.Assert(pc.ExprToPB(expr), NotNil, Commentf("%v", expr))
+	}
+
+	pc.SetNewCollationEnabled(false)
+	c.Assert(pc.ExprToPB(ciEQ), IsNil)
+	c.Assert(pc.ExprToPB(ciLike), IsNil)
+	c.Assert(pc.ExprToPB(binEQ), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestInColumnList2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	// a IN (b, c) where a, b and c are integer columns of different types.
+	cols := []Expression{dg.genColumn(mysql.TypeLong, 1), dg.genColumn(mysql.TypeLonglong, 2), dg.genColumn(mysql.TypeTiny, 3)}
+	fc, err := NewFunction(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeUnspecified), cols...)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	// The arguments are unified to be compared as integers.
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_InInt)
+	c.Assert(pbExpr.Children, HasLen, 3)
+	for i, child := range pbExpr.Children {
+		c.Assert(child.Tp, Equals, tipb.ExprType_ColumnRef)
+		c.Assert(child.FieldType.Tp, Equals, int32(cols[i].GetType().Tp))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCorrelatedColumn2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	// t.a = outer.b where outer.b is a correlated column of a subquery.
+	corCol := &CorrelatedColumn{Column: *dg.genColumn(mysql.TypeLonglong, 2), Data: new(types.Datum)}
+	fc, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), corCol)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.ExprToPB(corCol), IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, IsNil)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+}
+
+// evalPushedExpr converts pbExpr back to an expression as the coprocessor of mocktikv does, and evaluates it on row.
+func evalPushedExpr(c *C, sc *stmtctx.StatementContext, pbExpr *tipb.Expr, fieldTps []*types.FieldType, row types.DatumRow) types.Datum {
+	expr, err := PBToExpr(pbExpr, fieldTps, sc)
+	c.Assert(err, IsNil)
+	d, err := expr.Eval(row)
+	c.Assert(err, IsNil)
+	return d
+}
+
+func (s *testEvaluatorSuite) TestLikePercentOnly2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+
+	colTp := types.NewFieldType(mysql.TypeVarchar)
+	colTp.Charset, colTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	col := &Column{RetType: colTp, Index: 0}
+	fc, err := NewFunction(mock.NewContext(), ast.Like, types.NewFieldType(mysql.TypeUnspecified), col,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("%")},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64('\\'))})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LikeSig)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_String)
+	c.Assert(string(pbExpr.Children[1].Val), Equals, "%")
+
+	// `col LIKE '%'` matches every non-NULL value, including the empty string, but not NULL.
+	fieldTps := []*types.FieldType{colTp}
+	tests := []struct {
+		val    types.Datum
+		result types.Datum
+	}{
+		{types.NewStringDatum("abc"), types.NewIntDatum(1)},
+		{types.NewStringDatum(""), types.NewIntDatum(1)},
+		{types.NewStringDatum("%"), types.NewIntDatum(1)},
+		{types.Datum{}, types.Datum{}},
+	}
+	for _, tt := range tests {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{tt.val})
+		c.Assert(d, DeepEquals, tt.result, Commentf("%v", tt.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestLikeEscapedWildcard2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+
+	colTp := types.NewFieldType(mysql.TypeVarchar)
+	colTp.Charset, colTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	col := &Column{RetType: colTp, Index: 0}
+	fc, err := NewFunction(mock.NewContext(), ast.Like, types.NewFieldType(mysql.TypeUnspecified), col,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum(`a\%b`)},
+		&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64('\\'))})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LikeSig)
+	c.Assert(pbExpr.Children, HasLen, 3)
+	c.Assert(string(pbExpr.Children[1].Val), Equals, `a\%b`)
+	c.Assert(pbExpr.Children[2].Tp, Equals, tipb.ExprType_Int64)
+	c.Assert(pbExpr.Children[2].Val, DeepEquals, codec.EncodeInt(nil, int64('\\')))
+
+	// `col LIKE 'a\%b'` matches the literal percent only.
+	fieldTps := []*types.FieldType{colTp}
+	for val, result := range map[string]int64{"a%b": 1, "axb": 0, "axxb": 0} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewStringDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestYearArithmetic2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	yearCol := dg.genColumn(mysql.TypeYear, 1)
+
+	sigs := map[string]tipb.ScalarFuncSig{
+		ast.Plus:  tipb.ScalarFuncSig_PlusInt,
+		ast.Minus: tipb.ScalarFuncSig_MinusInt,
+	}
+	for funcName, sig := range sigs {
+		arith, err := NewFunction(mock.NewContext(), funcName, types.NewFieldType(mysql.TypeUnspecified), yearCol, One)
+		c.Assert(err, IsNil)
+		c.Assert(arith.GetType().Tp, Equals, mysql.TypeLonglong)
+		eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), arith,
+			&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(2024))})
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQInt)
+		c.Assert(pbExpr.Children[0].Sig, Equals, sig)
+		c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(mysql.TypeLonglong))
+	}
+}
+
+func (s *testEvaluatorSuite) TestReferencedColumns(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col5 := dg.genColumn(mysql.TypeLonglong, 5)
+	col1 := dg.genColumn(mysql.TypeLonglong, 1)
+	col3 := dg.genColumn(mysql.TypeLonglong, 3)
+
+	// (col5 + col1 = col3) OR (col5 > 1)
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), col5, col1)
+	c.Assert(err, IsNil)
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), plus, col3)
+	c.Assert(err, IsNil)
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), col5, One)
+	c.Assert(err, IsNil)
+	or, err := NewFunction(mock.NewContext(), ast.LogicOr, types.NewFieldType(mysql.TypeUnspecified), eq, gt)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.ExprToPB(or), NotNil)
+	c.Assert(pc.ReferencedColumns(or), DeepEquals, []int{1, 3, 5})
+	c.Assert(pc.ReferencedColumns(One), HasLen, 0)
+}
+
+func (s *testEvaluatorSuite) TestFunctionsUsed(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col0 := dg.genColumn(mysql.TypeLonglong, 0)
+	col1 := dg.genColumn(mysql.TypeLonglong, 1)
+
+	// (col0 + col1 > 1) OR (col0 + 1 > col1)
+	plus0, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), col0, col1)
+	c.Assert(err, IsNil)
+	gt0, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), plus0, One)
+	c.Assert(err, IsNil)
+	plus1, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), col0, One)
+	c.Assert(err, IsNil)
+	gt1, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), plus1, col1)
+	c.Assert(err, IsNil)
+	or, err := NewFunction(mock.NewContext(), ast.LogicOr, types.NewFieldType(mysql.TypeUnspecified), gt0, gt1)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.ExprToPB(or), NotNil)
+	c.Assert(pc.FunctionsUsed(or), DeepEquals, []string{ast.GT, ast.LogicOr, ast.Plus})
+	c.Assert(pc.FunctionsUsed(col0), HasLen, 0)
+}
+
+func (s *testEvaluatorSuite) TestExprDepth(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col0 := dg.genColumn(mysql.TypeLonglong, 0)
+	col1 := dg.genColumn(mysql.TypeLonglong, 1)
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.ExprDepth(col0), Equals, 0)
+	c.Assert(pc.ExprDepth(One), Equals, 0)
+
+	// col0 > 1
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), col0, One)
+	c.Assert(err, IsNil)
+	c.Assert(pc.ExprDepth(gt), Equals, 1)
+
+	// col0 + col1 = 1
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), col0, col1)
+	c.Assert(err, IsNil)
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), plus, One)
+	c.Assert(err, IsNil)
+	c.Assert(pc.ExprDepth(eq), Equals, 2)
+
+	// ((col0 > 1) AND (col0 > 1)) AND ... with 10 AND functions.
+	and := gt
+	for i := 0; i < 10; i++ {
+		and, err = NewFunction(mock.NewContext(), ast.LogicAnd, types.NewFieldType(mysql.TypeUnspecified), and, gt)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(pc.ExprToPB(and), NotNil)
+	c.Assert(pc.ExprDepth(and), Equals, 11)
+}
+
+func (s *testEvaluatorSuite) TestJSONUnquoteExtract2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	// col->>'$.name' = 'foo'
+	extract, err := NewFunction(mock.NewContext(), ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeJSON, 1),
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.name")})
+	c.Assert(err, IsNil)
+	unquote, err := NewFunction(mock.NewContext(), ast.JSONUnquote, types.NewFieldType(mysql.TypeUnspecified), extract)
+	c.Assert(err, IsNil)
+	c.Assert(unquote.GetType().EvalType(), Equals, types.ETString)
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), unquote,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("foo")})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQString)
+	pbUnquote := pbExpr.Children[0]
+	c.Assert(pbUnquote.Sig, Equals, tipb.ScalarFuncSig_JsonUnquoteSig)
+	c.Assert(pbUnquote.FieldType.Tp, Equals, int32(unquote.GetType().Tp))
+	c.Assert(pbUnquote.Children[0].Sig, Equals, tipb.ScalarFuncSig_JsonExtractSig)
+	c.Assert(pbUnquote.Children[0].Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+}
+
+func (s *testEvaluatorSuite) TestDateCompareString2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = time.UTC
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	dateCol := dg.genColumn(mysql.TypeDate, 0)
+
+	// dateCol = '2020-01-01'
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), dateCol,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("2020-01-01")})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	// The string literal is folded into a time constant, so both sides are compared as times.
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQTime)
+	c.Assert(pbExpr.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(mysql.TypeDate))
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlTime)
+
+	fieldTps := []*types.FieldType{dateCol.RetType}
+	tests := []struct {
+		val    string
+		result int64
+	}{
+		{"2020-01-01", 1},
+		{"2020-01-02", 0},
+	}
+	for _, tt := range tests {
+		t, err := types.ParseDate(sc, tt.val)
+		c.Assert(err, IsNil)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewTimeDatum(t)})
+		c.Assert(d.GetInt64(), Equals, tt.result, Commentf("%s", tt.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestInFunctionList2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	cols := make([]Expression, 0, 5)
+	for i := 0; i < 5; i++ {
+		cols = append(cols, dg.genColumn(mysql.TypeLonglong, int64(i)))
+	}
+
+	// col IN (a + b, c - d) pushes with all the members converted.
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), cols[1], cols[2])
+	c.Assert(err, IsNil)
+	minus, err := NewFunction(mock.NewContext(), ast.Minus, types.NewFieldType(mysql.TypeUnspecified), cols[3], cols[4])
+	c.Assert(err, IsNil)
+	in, err := NewFunction(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeUnspecified), cols[0], plus, minus)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{in}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_InInt)
+	c.Assert(pbExpr.Children, HasLen, 3)
+	c.Assert(pbExpr.Children[1].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+	c.Assert(pbExpr.Children[2].Sig, Equals, tipb.ScalarFuncSig_MinusInt)
+
+	// col IN (GREATEST(a, b), LEAST(c, d)) can't be pushed since GREATEST and LEAST can't.
+	greatest, err := NewFunction(mock.NewContext(), ast.Greatest, types.NewFieldType(mysql.TypeUnspecified), cols[1], cols[2])
+	c.Assert(err, IsNil)
+	least, err := NewFunction(mock.NewContext(), ast.Least, types.NewFieldType(mysql.TypeUnspecified), cols[3], cols[4])
+	c.Assert(err, IsNil)
+	in, err = NewFunction(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeUnspecified), cols[0], greatest, least)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained = ExpressionsToPB(sc, []Expression{in}, client)
+	c.Assert(pbExpr, IsNil)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+}
+
+func (s *testEvaluatorSuite) TestPushdownWhitelist2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), One)
+	c.Assert(err, IsNil)
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), plus, One)
+	c.Assert(err, IsNil)
+	lt, err := NewFunction(mock.NewContext(), ast.LT, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 2), One)
+	c.Assert(err, IsNil)
+	mod, err := NewFunction(mock.NewContext(), ast.Mod, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 3), One)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	pc.SetPushdownWhitelist(map[string]struct{}{ast.GT: {}, ast.Plus: {}, ast.Mod: {}})
+	// Functions in the whitelist are pushed.
+	c.Assert(pc.ExprToPB(gt), NotNil)
+	// Functions out of the whitelist are not pushed.
+	c.Assert(pc.ExprToPB(lt), IsNil)
+	// Functions in the whitelist but not supported are still not pushed.
+	c.Assert(pc.ExprToPB(mod), IsNil)
+	// A function whose argument contains a function out of the whitelist is not pushed.
+	pc.SetPushdownWhitelist(map[string]struct{}{ast.GT: {}})
+	c.Assert(pc.ExprToPB(gt), IsNil)
+
+	pc.SetPushdownWhitelist(nil)
+	c.Assert(pc.ExprToPB(gt), NotNil)
+	c.Assert(pc.ExprToPB(lt), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestPushdownBlacklist2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), One)
+	c.Assert(err, IsNil)
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), plus, One)
+	c.Assert(err, IsNil)
+	lt, err := NewFunction(mock.NewContext(), ast.LT, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 2), One)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	pc.SetPushdownBlacklist(map[string]struct{}{ast.Plus: {}})
+	// A function whose argument contains a function in the blacklist is not pushed.
+	c.Assert(pc.ExprToPB(gt), IsNil)
+	c.Assert(pc.ExprToPB(lt), NotNil)
+	// The blacklist wins over the whitelist.
+	pc.SetPushdownWhitelist(map[string]struct{}{ast.GT: {}, ast.Plus: {}, ast.LT: {}})
+	c.Assert(pc.ExprToPB(gt), IsNil)
+	c.Assert(pc.ExprToPB(lt), NotNil)
+
+	pc.SetPushdownBlacklist(nil)
+	c.Assert(pc.ExprToPB(gt), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestPushableColumns2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	partCol := dg.genColumn(mysql.TypeLonglong, 0)
+	partCol.ID = 1
+	otherCol := dg.genColumn(mysql.TypeLonglong, 1)
+	otherCol.ID = 2
+
+	// partCol = otherCol AND partCol > 1
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), partCol, otherCol)
+	c.Assert(err, IsNil)
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), partCol, One)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	pc.SetPushableColumns([]int64{partCol.ID})
+	// The predicate referencing a column out of the set is kept.
+	pbExpr, reason := pc.ExprToPBWithReason(eq)
+	c.Assert(pbExpr, IsNil)
+	c.Assert(reason, Equals, "column "+otherCol.String()+" not pushable")
+	c.Assert(pc.ExprToPB(gt), NotNil)
+
+	pc.SetPushableColumns(nil)
+	c.Assert(pc.ExprToPB(eq), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestCheckArgTypes2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	fc, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeVarchar, 1), dg.genColumn(mysql.TypeVarchar, 2))
+	c.Assert(err, IsNil)
+	pc := NewPBConverter(client, sc)
+	pc.SetCheckArgTypes(true)
+	c.Assert(pc.ExprToPB(fc), NotNil)
+
+	// Compare a string with a JSON without casting it.
+	fc.(*ScalarFunction).GetArgs()[1] = dg.genColumn(mysql.TypeJSON, 2)
+	c.Assert(pc.ExprToPB(fc), IsNil)
+	pc.SetCheckArgTypes(false)
+	c.Assert(pc.ExprToPB(fc), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestEncodeConstant(c *C) {
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = time.UTC
+	dur, err := types.ParseDuration("12:34:56", 0)
+	c.Assert(err, IsNil)
+	dt, err := types.ParseDatetime(sc, "2018-01-02 12:34:56")
+	c.Assert(err, IsNil)
+	packed, err := dt.ToPackedUint()
+	c.Assert(err, IsNil)
+	dec := types.NewDecFromStringForTest("12.345")
+
+	tests := []struct {
+		val types.Datum
+		tp  tipb.ExprType
+		enc []byte
+	}{
+		{types.Datum{}, tipb.ExprType_Null, nil},
+		{types.NewIntDatum(-100), tipb.ExprType_Int64, codec.EncodeInt(nil, -100)},
+		{types.NewUintDatum(100), tipb.ExprType_Uint64, codec.EncodeUint(nil, 100)},
+		{types.NewStringDatum("abc"), tipb.ExprType_String, []byte("abc")},
+		{types.NewBinaryLiteralDatum(types.BinaryLiteral("\x0a")), tipb.ExprType_String, []byte("\x0a")},
+		{types.NewBytesDatum([]byte("abc")), tipb.ExprType_Bytes, []byte("abc")},
+		{types.NewFloat32Datum(1.5), tipb.ExprType_Float32, codec.EncodeFloat(nil, 1.5)},
+		{types.NewFloat64Datum(2.5), tipb.ExprType_Float64, codec.EncodeFloat(nil, 2.5)},
+		{types.NewDurationDatum(dur), tipb.ExprType_MysqlDuration, codec.EncodeInt(nil, int64(dur.Duration))},
+		{types.NewDecimalDatum(dec), tipb.ExprType_MysqlDecimal, codec.EncodeDecimal(nil, dec, 5, 3)},
+		{types.NewTimeDatum(dt), tipb.ExprType_MysqlTime, codec.EncodeUint(nil, packed)},
+	}
+	for _, tt := range tests {
+		val, tp, err := EncodeConstant(sc, &Constant{Value: tt.val, RetType: types.NewFieldType(mysql.TypeUnspecified)})
+		c.Assert(err, IsNil, Commentf("%v", tt.val))
+		c.Assert(tp, Equals, tt.tp, Commentf("%v", tt.val))
+		c.Assert(val, DeepEquals, tt.enc, Commentf("%v", tt.val))
+	}
+
+	_, _, err = EncodeConstant(sc, &Constant{Value: types.MaxValueDatum(), RetType: types.NewFieldType(mysql.TypeUnspecified)})
+	c.Assert(err, NotNil)
+}
+
+func (s *testEvaluatorSuite) TestTemporalCoalesce2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	tests := []struct {
+		argTps []byte
+		retTp  byte
+	}{
+		{[]byte{mysql.TypeDate, mysql.TypeDate, mysql.TypeDate}, mysql.TypeDate},
+		{[]byte{mysql.TypeDate, mysql.TypeDatetime, mysql.TypeDate}, mysql.TypeDatetime},
+	}
+	for _, tt := range tests {
+		args := make([]Expression, 0, len(tt.argTps))
+		for i, tp := range tt.argTps {
+			args = append(args, dg.genColumn(tp, int64(i+1)))
+		}
+		fc, err := NewFunction(mock.NewContext(), ast.Coalesce, types.NewFieldType(mysql.TypeUnspecified), args...)
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_CoalesceTime)
+		c.Assert(pbExpr.FieldType.Tp, Equals, int32(tt.retTp))
+		c.Assert(pbExpr.Children, HasLen, 3)
+		for i, child := range pbExpr.Children {
+			c.Assert(child.Tp, Equals, tipb.ExprType_ColumnRef)
+			c.Assert(child.FieldType.Tp, Equals, int32(tt.argTps[i]))
+		}
+	}
+}
+
+func (s *testEvaluatorSuite) TestDecimalPlusFloat2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	for _, floatTp := range []byte{mysql.TypeFloat, mysql.TypeDouble} {
+		fc, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeNewDecimal, 1), dg.genColumn(floatTp, 2))
+		c.Assert(err, IsNil)
+		// The decimal is promoted to double instead of the float being converted to decimal.
+		c.Assert(fc.GetType().Tp, Equals, mysql.TypeDouble)
+		cast, ok := fc.(*ScalarFunction).GetArgs()[0].(*ScalarFunction)
+		c.Assert(ok, IsTrue)
+		c.Assert(cast.FuncName.L, Equals, ast.Cast)
+		c.Assert(cast.GetType().EvalType(), Equals, types.ETReal)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_PlusReal)
+		c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_CastDecimalAsReal)
+		c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(mysql.TypeDouble))
+	}
+}
+
+func (s *testEvaluatorSuite) TestJSONExtractIsNull2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	jsonCol := dg.genColumn(mysql.TypeJSON, 0)
+
+	// JSON_EXTRACT(doc, '$.a') IS NULL
+	extract, err := NewFunction(mock.NewContext(), ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), jsonCol,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.a")})
+	c.Assert(err, IsNil)
+	isNull, err := NewFunction(mock.NewContext(), ast.IsNull, types.NewFieldType(mysql.TypeUnspecified), extract)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{isNull}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_JsonIsNull)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_JsonExtractSig)
+
+	// A missing path results in a SQL NULL, while a JSON null literal is not NULL.
+	fieldTps := []*types.FieldType{jsonCol.RetType}
+	tests := []struct {
+		doc    interface{}
+		result int64
+	}{
+		{`{"a": 1}`, 0},
+		{`{"a": null}`, 0},
+		{`{"b": 1}`, 1},
+		{nil, 1},
+	}
+	for _, tt := range tests {
+		doc := types.NewDatum(tt.doc)
+		doc, err = doc.ConvertTo(sc, jsonCol.RetType)
+		c.Assert(err, IsNil)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{doc})
+		c.Assert(d.GetInt64(), Equals, tt.result, Commentf("%v", tt.doc))
+	}
+}
+
+func (s *testEvaluatorSuite) TestTiFlashProjection2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	neg, err := NewFunction(mock.NewContext(), ast.UnaryMinus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1))
+	c.Assert(err, IsNil)
+	isTrue, err := NewFunction(mock.NewContext(), ast.IsTruth, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	mod, err := NewFunction(mock.NewContext(), ast.Mod, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	exprs := []Expression{neg, isTrue, plus, mod}
+
+	pbExprs := ExpressionsToPBList(sc, exprs, client)
+	c.Assert(pbExprs, HasLen, 4)
+	c.Assert(pbExprs[0], IsNil)
+	c.Assert(pbExprs[1], IsNil)
+	c.Assert(pbExprs[2], NotNil)
+	c.Assert(pbExprs[3], IsNil)
+
+	pc := NewPBConverter(client, sc)
+	pc.SetTiFlashProjection(true)
+	pbExprs = pc.ExpressionsToPBList(exprs)
+	c.Assert(pbExprs, HasLen, 4)
+	c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_UnaryMinusInt)
+	c.Assert(pbExprs[1].Sig, Equals, tipb.ScalarFuncSig_IntIsTrue)
+	c.Assert(pbExprs[2].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+	// MOD has no signature, so it can't be pushed down to TiFlash either.
+	c.Assert(pbExprs[3], IsNil)
+
+	// The functions TiFlash can't evaluate are not pushed to its projections.
+	extract, err := NewFunction(mock.NewContext(), ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeJSON, 1),
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.a")})
+	c.Assert(err, IsNil)
+	c.Assert(pc.ExprToPB(extract), IsNil)
+	pc = NewPBConverter(client, sc)
+	c.Assert(pc.ExprToPB(extract), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestStoreType2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	neg, err := NewFunction(mock.NewContext(), ast.UnaryMinus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1))
+	c.Assert(err, IsNil)
+	jsonType, err := NewFunction(mock.NewContext(), ast.JSONType, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeJSON, 2))
+	c.Assert(err, IsNil)
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), dg.genColumn(mysql.TypeLonglong, 1), dg.genColumn(mysql.TypeLonglong, 2))
+	c.Assert(err, IsNil)
+	exprs := []Expression{neg, jsonType, plus}
+
+	pc := NewPBConverter(client, sc)
+	pbExprs := pc.ExpressionsToPBList(exprs)
+	c.Assert(pbExprs[0], IsNil)
+	c.Assert(pbExprs[1].Sig, Equals, tipb.ScalarFuncSig_JsonTypeSig)
+	c.Assert(pbExprs[2].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+
+	// TiFlash can evaluate UnaryMinus in filters too, but not the JSON functions.
+	pc.SetStoreType(kv.TiFlash)
+	pbExprs = pc.ExpressionsToPBList(exprs)
+	c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_UnaryMinusInt)
+	c.Assert(pbExprs[1], IsNil)
+	c.Assert(pbExprs[2].Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+
+	// The client is still checked.
+	noColClient := &capabilityClient{supported: map[tipb.ExprType]bool{tipb.ExprType_ColumnRef: false}}
+	pc = NewPBConverter(noColClient, sc)
+	pc.SetStoreType(kv.TiFlash)
+	c.Assert(pc.ExprToPB(neg), IsNil)
+}
+
+func (s *testEvaluatorSuite) TestBinaryLiteralCompare2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+
+	colTp := types.NewFieldType(mysql.TypeVarchar)
+	colTp.Charset, colTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	col := &Column{RetType: colTp, Index: 0}
+	// col = 0x48656C6C6F
+	lit, err := types.ParseHexStr("0x48656C6C6F")
+	c.Assert(err, IsNil)
+	litTp := types.NewFieldType(mysql.TypeVarString)
+	types.SetBinChsClnFlag(litTp)
+	fc, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), col,
+		&Constant{RetType: litTp, Value: types.NewBinaryLiteralDatum(lit)})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQString)
+	pbLit := pbExpr.Children[1]
+	c.Assert(pbLit.Tp, Equals, tipb.ExprType_String)
+	c.Assert(pbLit.Val, DeepEquals, []byte("Hello"))
+	c.Assert(pbLit.FieldType.Charset, Equals, charset.CharsetBin)
+	c.Assert(pbLit.FieldType.Collate, Equals, int32(mysql.CollationNames[charset.CollationBin]))
+	c.Assert(mysql.HasBinaryFlag(uint(pbLit.FieldType.Flag)), IsTrue)
+
+	fieldTps := []*types.FieldType{colTp}
+	tests := []struct {
+		val    string
+		result int64
+	}{
+		{"Hello", 1},
+		{"hello", 0},
+		{"Hello ", 0},
+	}
+	for _, tt := range tests {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewStringDatum(tt.val)})
+		c.Assert(d.GetInt64(), Equals, tt.result, Commentf("%q", tt.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestConstantEvalCache2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	con := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewDatum(int64(1))}
+	args := []Expression{dg.genColumn(mysql.TypeLonglong, 1)}
+	for i := 0; i < 50; i++ {
+		args = append(args, con)
+	}
+	fc, err := NewFunction(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeUnspecified), args...)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	pbExpr := pc.ExprToPB(fc)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Children, HasLen, 51)
+	for _, child := range pbExpr.Children[1:] {
+		c.Assert(child.Tp, Equals, tipb.ExprType_Int64)
+		c.Assert(child.Val, DeepEquals, codec.EncodeInt(nil, 1))
+	}
+	// The constant is evaluated only once.
+	c.Assert(pc.evaluatedConsts, HasLen, 1)
+}
+
+func (s *testEvaluatorSuite) TestYearCompareString2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	yearCol := dg.genColumn(mysql.TypeYear, 0)
+
+	// The string literals are converted to years, and two-digit years are expanded.
+	tests := []struct {
+		str  string
+		year int64
+	}{
+		{"2023", 2023},
+		{"23", 2023},
+		{"99", 1999},
+	}
+	for _, tt := range tests {
+		eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), yearCol,
+			&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum(tt.str)})
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQInt)
+		c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Int64)
+		c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeInt(nil, tt.year), Commentf("%s", tt.str))
+	}
+
+	// An unconvertible literal is refined like the strings compared with other integer columns.
+	intCol := dg.genColumn(mysql.TypeLonglong, 0)
+	var pbExprs []*tipb.Expr
+	for _, col := range []*Column{yearCol, intCol} {
+		eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), col,
+			&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("abc")})
+		c.Assert(err, IsNil)
+		pbExpr, pushed, _ := ExpressionsToPB(sc, []Expression{eq}, client)
+		c.Assert(pushed, HasLen, 1)
+		pbExprs = append(pbExprs, pbExpr)
+	}
+	c.Assert(pbExprs[0].Sig, Equals, pbExprs[1].Sig)
+	c.Assert(pbExprs[0].Children[1], DeepEquals, pbExprs[1].Children[1])
+
+	// yearCol = ? is not converted to a year, since the deferred expression of the converted
+	// LONGLONG constant would be evaluated to 23 rather than 2023 by the next execution.
+	// The parameter is compared as a real like the other strings compared with integers.
+	str := &Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("23")}
+	param := &Constant{RetType: str.RetType, Value: str.Value, DeferredExpr: str}
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), yearCol, param)
+	c.Assert(err, IsNil)
+	arg := eq.(*ScalarFunction).GetArgs()[1].(*Constant)
+	c.Assert(arg.DeferredExpr, NotNil)
+	c.Assert(arg.GetType().Tp, Equals, mysql.TypeDouble)
+	c.Assert(eq.(*ScalarFunction).Function.PbCode(), Equals, tipb.ScalarFuncSig_EQReal)
+}
+
+func (s *testEvaluatorSuite) TestIntCompareDecimal2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	intCol := dg.genColumn(mysql.TypeLonglong, 0)
+	newDecimal := func(s string) *Constant {
+		return &Constant{RetType: types.NewFieldType(mysql.TypeNewDecimal), Value: types.NewDecimalDatum(types.NewDecFromStringForTest(s))}
+	}
+
+	// intCol = 5.0 is refined to intCol = 5.
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), intCol, newDecimal("5.0"))
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQInt)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Int64)
+	fieldTps := []*types.FieldType{intCol.RetType}
+	for val, result := range map[int64]int64{5: 1, 6: 0} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%d", val))
+	}
+
+	// intCol = 5.5 is compared as decimals with the column cast to decimal.
+	eq, err = NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), intCol, newDecimal("5.5"))
+	c.Assert(err, IsNil)
+	c.Assert(eq.(*ScalarFunction).Function.PbCode(), Equals, tipb.ScalarFuncSig_EQDecimal)
+	pbExpr, pushed, remained = ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_CastIntAsDecimal)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlDecimal)
+	for _, val := range []int64{5, 6} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(val)})
+		c.Assert(d.GetInt64(), Equals, int64(0), Commentf("%d", val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCoalesceCharset2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	newStrCol := func(idx int, chs, coll string) *Column {
+		ft := types.NewFieldType(mysql.TypeVarchar)
+		ft.Charset, ft.Collate = chs, coll
+		return &Column{RetType: ft, Index: idx}
+	}
+
+	tests := []struct {
+		args []Expression
+		chs  string
+		coll string
+	}{
+		{
+			[]Expression{newStrCol(0, charset.CharsetUTF8, charset.CollationUTF8), newStrCol(1, charset.CharsetLatin1, charset.CollationLatin1)},
+			charset.CharsetUTF8, charset.CollationUTF8,
+		},
+		{
+			[]Expression{newStrCol(0, charset.CharsetLatin1, charset.CollationLatin1), newStrCol(1, charset.CharsetLatin1, charset.CollationLatin1)},
+			charset.CharsetLatin1, charset.CollationLatin1,
+		},
+		{
+			[]Expression{newStrCol(0, charset.CharsetUTF8, charset.CollationUTF8), newStrCol(1, charset.CharsetUTF8MB4, charset.CollationUTF8MB4)},
+			charset.CharsetUTF8MB4, charset.CollationUTF8MB4,
+		},
+		{
+			[]Expression{newStrCol(0, charset.CharsetUTF8, charset.CollationUTF8), newStrCol(1, charset.CharsetBin, charset.CollationBin)},
+			charset.CharsetBin, charset.CollationBin,
+		},
+	}
+	for _, tt := range tests {
+		fc, err := NewFunction(mock.NewContext(), ast.Coalesce, types.NewFieldType(mysql.TypeUnspecified), tt.args...)
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{fc}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_CoalesceString)
+		c.Assert(pbExpr.FieldType.Charset, Equals, tt.chs)
+		c.Assert(pbExpr.FieldType.Collate, Equals, int32(mysql.CollationNames[tt.coll]))
+		// The binary flag is set along with the binary charset and collation.
+		c.Assert(mysql.HasBinaryFlag(uint(pbExpr.FieldType.Flag)), Equals, tt.chs == charset.CharsetBin, Commentf("%s", tt.chs))
+	}
+}
+
+func (s *testEvaluatorSuite) TestBitwiseNull2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col := dg.genColumn(mysql.TypeLonglong, 0)
+	pc := NewPBConverter(client, sc)
+
+	// ~NULL is folded to a NULL constant, which is pushed as NULL rather than an unsigned 0.
+	neg, err := NewFunction(mock.NewContext(), ast.BitNeg, types.NewFieldType(mysql.TypeUnspecified), Null)
+	c.Assert(err, IsNil)
+	c.Assert(mysql.HasUnsignedFlag(neg.GetType().Flag), IsTrue)
+	pbExpr := pc.ExprToPB(neg)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Tp, Equals, tipb.ExprType_Null)
+	c.Assert(pbExpr.Val, IsNil)
+
+	// col & NULL and col | NULL yield NULL on TiKV.
+	fieldTps := []*types.FieldType{col.RetType}
+	sigs := map[string]tipb.ScalarFuncSig{
+		ast.And: tipb.ScalarFuncSig_BitAndSig,
+		ast.Or:  tipb.ScalarFuncSig_BitOrSig,
+	}
+	for funcName, sig := range sigs {
+		fc, err := NewFunction(mock.NewContext(), funcName, types.NewFieldType(mysql.TypeUnspecified), col, Null)
+		c.Assert(err, IsNil)
+		pbExpr := pc.ExprToPB(fc)
+		c.Assert(pbExpr, NotNil, Commentf("%s", funcName))
+		c.Assert(pbExpr.Sig, Equals, sig)
+		c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Null)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(5)})
+		c.Assert(d.IsNull(), IsTrue, Commentf("%s", funcName))
+	}
+}
+
+func (s *testEvaluatorSuite) TestSignedCompareUnsigned2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	signedCol := dg.genColumn(mysql.TypeLonglong, 0)
+	maxUint := &Constant{
+		RetType: &types.FieldType{Tp: mysql.TypeLonglong, Flag: mysql.UnsignedFlag},
+		Value:   types.NewUintDatum(math.MaxUint64),
+	}
+
+	// signedCol < 18446744073709551615
+	lt, err := NewFunction(mock.NewContext(), ast.LT, types.NewFieldType(mysql.TypeUnspecified), signedCol, maxUint)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{lt}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LTInt)
+	c.Assert(mysql.HasUnsignedFlag(uint(pbExpr.Children[0].FieldType.Flag)), IsFalse)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Uint64)
+	c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeUint(nil, math.MaxUint64))
+	c.Assert(mysql.HasUnsignedFlag(uint(pbExpr.Children[1].FieldType.Flag)), IsTrue)
+
+	// Every signed value is less than the maximum unsigned value in MySQL.
+	fieldTps := []*types.FieldType{signedCol.RetType}
+	for _, val := range []int64{math.MinInt64, -1, 0, math.MaxInt64} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(val)})
+		c.Assert(d.GetInt64(), Equals, int64(1), Commentf("%d", val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestPreConvert2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col := dg.genColumn(mysql.TypeLonglong, 0)
+	ctx := mock.NewContext()
+
+	abs, err := NewFunction(ctx, ast.Abs, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+	pc := NewPBConverter(client, sc)
+	pbExpr := pc.ExprToPB(abs)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_AbsInt)
+
+	// ABS(x) is rewritten to IF(x < 0, 0 - x, x), since unary minus can't be pushed to TiKV.
+	pc.SetPreConvert(func(expr Expression) Expression {
+		sf, ok := expr.(*ScalarFunction)
+		if !ok || sf.FuncName.L != ast.Abs {
+			return expr
+		}
+		x := sf.GetArgs()[0]
+		zero := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(0)}
+		lt, err := NewFunction(ctx, ast.LT, types.NewFieldType(mysql.TypeUnspecified), x, zero)
+		c.Assert(err, IsNil)
+		neg, err := NewFunction(ctx, ast.Minus, types.NewFieldType(mysql.TypeUnspecified), zero, x)
+		c.Assert(err, IsNil)
+		ifFunc, err := NewFunction(ctx, ast.If, sf.RetType, lt, neg, x)
+		c.Assert(err, IsNil)
+		return ifFunc
+	})
+	pbExpr = pc.ExprToPB(abs)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_IfInt)
+	c.Assert(pbExpr.Children, HasLen, 3)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_LTInt)
+	c.Assert(pbExpr.Children[1].Sig, Equals, tipb.ScalarFuncSig_MinusInt)
+	c.Assert(pbExpr.Children[2].Tp, Equals, tipb.ExprType_ColumnRef)
+
+	fieldTps := []*types.FieldType{col.RetType}
+	for val, result := range map[int64]int64{-3: 3, 0: 0, 5: 5} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%d", val))
+	}
+
+	pc.SetPreConvert(nil)
+	pbExpr = pc.ExprToPB(abs)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_AbsInt)
+}
+
+func (s *testEvaluatorSuite) TestEmptyStringCompare2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	colTp := types.NewFieldType(mysql.TypeVarchar)
+	colTp.Charset, colTp.Collate = charset.CharsetBin, charset.CollationBin
+	col := &Column{RetType: colTp, Index: 0}
+
+	// col = ''
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), col,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewStringDatum("")})
+	c.Assert(err, IsNil)
+	// col IS NULL
+	isNull, err := NewFunction(mock.NewContext(), ast.IsNull, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+
+	pc := NewPBConverter(client, sc)
+	eqPB := pc.ExprToPB(eq)
+	c.Assert(eqPB, NotNil)
+	c.Assert(eqPB.Sig, Equals, tipb.ScalarFuncSig_EQString)
+	c.Assert(eqPB.Children[1].Tp, Equals, tipb.ExprType_String)
+	c.Assert(eqPB.Children[1].Val, HasLen, 0)
+	isNullPB := pc.ExprToPB(isNull)
+	c.Assert(isNullPB, NotNil)
+	c.Assert(isNullPB.Sig, Equals, tipb.ScalarFuncSig_StringIsNull)
+	c.Assert(isNullPB.Children, HasLen, 1)
+
+	fieldTps := []*types.FieldType{colTp}
+	tests := []struct {
+		val    types.Datum
+		eq     types.Datum
+		isNull types.Datum
+	}{
+		{types.NewStringDatum(""), types.NewIntDatum(1), types.NewIntDatum(0)},
+		{types.NewStringDatum("a"), types.NewIntDatum(0), types.NewIntDatum(0)},
+		{types.Datum{}, types.Datum{}, types.NewIntDatum(1)},
+	}
+	for _, tt := range tests {
+		d := evalPushedExpr(c, sc, eqPB, fieldTps, types.DatumRow{tt.val})
+		c.Assert(d, DeepEquals, tt.eq, Commentf("%v", tt.val))
+		d = evalPushedExpr(c, sc, isNullPB, fieldTps, types.DatumRow{tt.val})
+		c.Assert(d, DeepEquals, tt.isNull, Commentf("%v", tt.val))
+	}
+}
+
+// pbExprDepth returns the nesting depth of the scalar functions in pbExpr.
+func pbExprDepth(pbExpr *tipb.Expr) int {
+	if pbExpr.Tp != tipb.ExprType_ScalarFunc {
+		return 0
+	}
+	depth := 0
+	for _, child := range pbExpr.Children {
+		if d := pbExprDepth(child); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+func (s *testEvaluatorSuite) TestRebalanceAssociative2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	// col0 + col1 + ... + col15
+	fieldTps := make([]*types.FieldType, 0, 16)
+	row := make(types.DatumRow, 0, 16)
+	var sum Expression
+	for i := 0; i < 16; i++ {
+		col := dg.genColumn(mysql.TypeLonglong, int64(i))
+		fieldTps = append(fieldTps, col.RetType)
+		row = append(row, types.NewIntDatum(int64(i)))
+		if sum == nil {
+			sum = col
+			continue
+		}
+		var err error
+		sum, err = NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), sum, col)
+		c.Assert(err, IsNil)
+	}
+
+	pc := NewPBConverter(client, sc)
+	pbExpr := pc.ExprToPB(sum)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExprDepth(pbExpr), Equals, 15)
+	c.Assert(pbExprDepth(pbExpr), Equals, pc.ExprDepth(sum))
+
+	pc.SetRebalanceAssociative(true)
+	balanced := pc.ExprToPB(sum)
+	c.Assert(balanced, NotNil)
+	c.Assert(balanced.Sig, Equals, tipb.ScalarFuncSig_PlusInt)
+	c.Assert(pbExprDepth(balanced), Equals, 4)
+	c.Assert(balanced.Children[0].Children[0].Children[0].Children[0].Val, DeepEquals, codec.EncodeInt(nil, 0))
+	c.Assert(balanced.Children[1].Children[1].Children[1].Children[1].Val, DeepEquals, codec.EncodeInt(nil, 15))
+	c.Assert(evalPushedExpr(c, sc, balanced, fieldTps, row), DeepEquals, evalPushedExpr(c, sc, pbExpr, fieldTps, row))
+
+	// Floating point additions are not regrouped.
+	realSum := Expression(dg.genColumn(mysql.TypeDouble, 0))
+	for i := 1; i < 4; i++ {
+		var err error
+		realSum, err = NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), realSum, dg.genColumn(mysql.TypeDouble, int64(i)))
+		c.Assert(err, IsNil)
+	}
+	pbExpr = pc.ExprToPB(realSum)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_PlusReal)
+	c.Assert(pbExprDepth(pbExpr), Equals, 3)
+}
+
+func (s *testEvaluatorSuite) TestJSONExtractWildcard2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	jsonCol := dg.genColumn(mysql.TypeJSON, 0)
+
+	// JSON_EXTRACT(jsonCol, '$[*].price')
+	extract, err := NewFunction(mock.NewContext(), ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), jsonCol,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$[*].price")})
+	c.Assert(err, IsNil)
+
+	pbExprs := ExpressionsToPBList(sc, []Expression{extract}, client)
+	c.Assert(pbExprs, HasLen, 1)
+	pbExpr := pbExprs[0]
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_JsonExtractSig)
+	c.Assert(pbExpr.FieldType.Tp, Equals, int32(mysql.TypeJSON))
+	c.Assert(pbExpr.Children, HasLen, 2)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_String)
+	c.Assert(string(pbExpr.Children[1].Val), Equals, "$[*].price")
+
+	// The wildcard path collects the matched values into an array.
+	str := types.NewStringDatum(`[{"price": 1}, {"name": "foo"}, {"price": 2}]`)
+	doc, err := str.ConvertTo(sc, jsonCol.RetType)
+	c.Assert(err, IsNil)
+	d := evalPushedExpr(c, sc, pbExpr, []*types.FieldType{jsonCol.RetType}, types.DatumRow{doc})
+	c.Assert(d.Kind(), Equals, types.KindMysqlJSON)
+	c.Assert(d.GetMysqlJSON().String(), Equals, "[1,2]")
+}
+
+func (s *testEvaluatorSuite) TestDurationCompareFraction2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	timeTp := types.NewFieldType(mysql.TypeDuration)
+	timeTp.Decimal = 6
+	timeCol := &Column{RetType: timeTp, Index: 0}
+
+	// timeCol > '00:00:01.500000'
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), timeCol,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("00:00:01.500000")})
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{gt}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_GTDuration)
+	c.Assert(pbExpr.Children[0].FieldType.Decimal, Equals, int32(6))
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlDuration)
+	c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeInt(nil, int64(1500*time.Millisecond)))
+	c.Assert(pbExpr.Children[1].FieldType.Decimal, Equals, int32(6))
+
+	fieldTps := []*types.FieldType{timeTp}
+	for str, result := range map[string]int64{"00:00:01.499999": 0, "00:00:01.500000": 0, "00:00:01.500001": 1} {
+		dur, err := types.ParseDuration(str, 6)
+		c.Assert(err, IsNil)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewDurationDatum(dur)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", str))
+	}
+}
+
+func (s *testEvaluatorSuite) TestNegatePushable(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	a := dg.genColumn(mysql.TypeLonglong, 0)
+	b := dg.genColumn(mysql.TypeLonglong, 1)
+	newInt := func(v int64) *Constant {
+		return &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(v)}
+	}
+	pc := NewPBConverter(client, sc)
+
+	// NOT (a = 5) is negated to a != 5.
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), a, newInt(5))
+	c.Assert(err, IsNil)
+	negated := pc.NegatePushable(eq)
+	c.Assert(negated, NotNil)
+	c.Assert(negated.(*ScalarFunction).FuncName.L, Equals, ast.NE)
+	pbExpr := pc.ExprToPB(negated)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_NEInt)
+	c.Assert(eq.(*ScalarFunction).FuncName.L, Equals, ast.EQ)
+
+	// NOT (a = 1 AND b = 2) is negated to a != 1 OR b != 2.
+	eqA, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), a, newInt(1))
+	c.Assert(err, IsNil)
+	eqB, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), b, newInt(2))
+	c.Assert(err, IsNil)
+	and, err := NewFunction(mock.NewContext(), ast.LogicAnd, types.NewFieldType(mysql.TypeUnspecified), eqA, eqB)
+	c.Assert(err, IsNil)
+	negated = pc.NegatePushable(and)
+	c.Assert(negated, NotNil)
+	c.Assert(negated.(*ScalarFunction).FuncName.L, Equals, ast.LogicOr)
+	pbExpr = pc.ExprToPB(negated)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LogicalOr)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_NEInt)
+	c.Assert(pbExpr.Children[1].Sig, Equals, tipb.ScalarFuncSig_NEInt)
+	c.Assert(and.(*ScalarFunction).GetArgs()[0].(*ScalarFunction).FuncName.L, Equals, ast.EQ)
+
+	fieldTps := []*types.FieldType{a.RetType, b.RetType}
+	for _, row := range []types.DatumRow{
+		{types.NewIntDatum(1), types.NewIntDatum(2)},
+		{types.NewIntDatum(1), types.NewIntDatum(3)},
+		{types.NewIntDatum(0), types.NewIntDatum(2)},
+	} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, row)
+		expected, err := and.Eval(row)
+		c.Assert(err, IsNil)
+		c.Assert(d.GetInt64(), Equals, 1-expected.GetInt64(), Commentf("%v", row))
+	}
+
+	// The negation of an expression which can't be pushed down is nil.
+	sign, err := NewFunction(mock.NewContext(), ast.Sign, types.NewFieldType(mysql.TypeUnspecified), a)
+	c.Assert(err, IsNil)
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), sign, newInt(1))
+	c.Assert(err, IsNil)
+	c.Assert(pc.NegatePushable(gt), IsNil)
+	c.Assert(pc.NegatePushable(a), IsNil)
+}
+
+func (s *testEvaluatorSuite) TestAbs2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	unsignedCol := dg.genColumn(mysql.TypeLonglong, 0)
+	unsignedCol.RetType.Flag |= mysql.UnsignedFlag
+
+	tests := []struct {
+		arg      *Column
+		sig      tipb.ScalarFuncSig
+		unsigned bool
+		val      types.Datum
+		result   types.Datum
+	}{
+		{dg.genColumn(mysql.TypeLonglong, 0), tipb.ScalarFuncSig_AbsInt, false, types.NewIntDatum(-5), types.NewIntDatum(5)},
+		{unsignedCol, tipb.ScalarFuncSig_AbsUInt, true, types.NewUintDatum(math.MaxUint64), types.NewUintDatum(math.MaxUint64)},
+		{dg.genColumn(mysql.TypeNewDecimal, 0), tipb.ScalarFuncSig_AbsDecimal, false,
+			types.NewDecimalDatum(types.NewDecFromStringForTest("-1.5")), types.NewDecimalDatum(types.NewDecFromStringForTest("1.5"))},
+		{dg.genColumn(mysql.TypeDouble, 0), tipb.ScalarFuncSig_AbsReal, false, types.NewFloat64Datum(-1.5), types.NewFloat64Datum(1.5)},
+	}
+	for _, tt := range tests {
+		abs, err := NewFunction(mock.NewContext(), ast.Abs, types.NewFieldType(mysql.TypeUnspecified), tt.arg)
+		c.Assert(err, IsNil)
+
+		pbExprs := ExpressionsToPBList(sc, []Expression{abs}, client)
+		c.Assert(pbExprs[0], NotNil)
+		c.Assert(pbExprs[0].Sig, Equals, tt.sig)
+		c.Assert(mysql.HasUnsignedFlag(uint(pbExprs[0].FieldType.Flag)), Equals, tt.unsigned)
+
+		d := evalPushedExpr(c, sc, pbExprs[0], []*types.FieldType{tt.arg.RetType}, types.DatumRow{tt.val})
+		cmp, err := d.CompareDatum(sc, &tt.result)
+		c.Assert(err, IsNil)
+		c.Assert(cmp, Equals, 0, Commentf("%v", tt.sig))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCeilFloor2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	newCol := func(tp byte, flen, decimal int) *Column {
+		ft := types.NewFieldType(tp)
+		ft.Flen, ft.Decimal = flen, decimal
+		return &Column{RetType: ft, Index: 0}
+	}
+
+	tests := []struct {
+		arg      *Column
+		ceilSig  tipb.ScalarFuncSig
+		floorSig tipb.ScalarFuncSig
+		retTp    byte
+	}{
+		{newCol(mysql.TypeLong, 11, 0), tipb.ScalarFuncSig_CeilIntToInt, tipb.ScalarFuncSig_FloorIntToInt, mysql.TypeLonglong},
+		{newCol(mysql.TypeLonglong, 20, 0), tipb.ScalarFuncSig_CeilIntToDec, tipb.ScalarFuncSig_FloorIntToDec, mysql.TypeNewDecimal},
+		{newCol(mysql.TypeNewDecimal, 10, 2), tipb.ScalarFuncSig_CeilDecToInt, tipb.ScalarFuncSig_FloorDecToInt, mysql.TypeLonglong},
+		{newCol(mysql.TypeNewDecimal, 30, 2), tipb.ScalarFuncSig_CeilDecToDec, tipb.ScalarFuncSig_FloorDecToDec, mysql.TypeNewDecimal},
+		{newCol(mysql.TypeDouble, 22, -1), tipb.ScalarFuncSig_CeilReal, tipb.ScalarFuncSig_FloorReal, mysql.TypeDouble},
+	}
+	for _, tt := range tests {
+		sigs := map[string]tipb.ScalarFuncSig{ast.Ceil: tt.ceilSig, ast.Ceiling: tt.ceilSig, ast.Floor: tt.floorSig}
+		for funcName, sig := range sigs {
+			fc, err := NewFunction(mock.NewContext(), funcName, types.NewFieldType(mysql.TypeUnspecified), tt.arg)
+			c.Assert(err, IsNil)
+			c.Assert(fc.GetType().Tp, Equals, tt.retTp)
+
+			pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+			c.Assert(pbExprs[0], NotNil, Commentf("%s %v", funcName, sig))
+			c.Assert(pbExprs[0].Sig, Equals, sig)
+			c.Assert(pbExprs[0].FieldType.Tp, Equals, int32(tt.retTp))
+			expr, err := PBToExpr(pbExprs[0], []*types.FieldType{tt.arg.RetType}, sc)
+			c.Assert(err, IsNil)
+			c.Assert(reflect.TypeOf(expr.(*ScalarFunction).Function), Equals, reflect.TypeOf(fc.(*ScalarFunction).Function))
+		}
+	}
+
+	// FLOOR(-1.5) = -2 and CEIL(-1.5) = -1.
+	decCol := newCol(mysql.TypeNewDecimal, 10, 1)
+	row := types.DatumRow{types.NewDecimalDatum(types.NewDecFromStringForTest("-1.5"))}
+	for funcName, result := range map[string]int64{ast.Floor: -2, ast.Ceil: -1} {
+		fc, err := NewFunction(mock.NewContext(), funcName, types.NewFieldType(mysql.TypeUnspecified), decCol)
+		c.Assert(err, IsNil)
+		pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+		c.Assert(pbExprs[0], NotNil)
+		d := evalPushedExpr(c, sc, pbExprs[0], []*types.FieldType{decCol.RetType}, row)
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", funcName))
+	}
+}
+
+func (s *testEvaluatorSuite) TestIntCompareString2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	intCol := dg.genColumn(mysql.TypeLonglong, 0)
+	strCol := dg.genColumn(mysql.TypeVarchar, 1)
+	ctx := mock.NewContext()
+
+	// intCol = strCol compares both as doubles with both columns cast to double.
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), intCol, strCol)
+	c.Assert(err, IsNil)
+	c.Assert(eq.(*ScalarFunction).Function.PbCode(), Equals, tipb.ScalarFuncSig_EQReal)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_CastIntAsReal)
+	c.Assert(pbExpr.Children[1].Sig, Equals, tipb.ScalarFuncSig_CastStringAsReal)
+
+	// The pushed comparison coerces the strings like MySQL.
+	sc.TruncateAsWarning = true
+	fieldTps := []*types.FieldType{intCol.RetType, strCol.RetType}
+	tests := []struct {
+		intVal int64
+		strVal string
+		result int64
+	}{
+		{1, "1", 1},
+		{1, "1.0", 1},
+		{1, "1abc", 1},
+		{0, "abc", 1},
+		{1, "abc", 0},
+		{2, "1", 0},
+	}
+	for _, tt := range tests {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(tt.intVal), types.NewStringDatum(tt.strVal)})
+		c.Assert(d.GetInt64(), Equals, tt.result, Commentf("%d = %s", tt.intVal, tt.strVal))
+	}
+}
+
+func (s *testEvaluatorSuite) TestTraceRequestID2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col := dg.genColumn(mysql.TypeLonglong, 0)
+
+	gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), col, One)
+	c.Assert(err, IsNil)
+	plus, err := NewFunction(mock.NewContext(), ast.Plus, types.NewFieldType(mysql.TypeUnspecified), col, One)
+	c.Assert(err, IsNil)
+	sign, err := NewFunction(mock.NewContext(), ast.Sign, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+	exprs := []Expression{gt, plus, sign}
+
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.TracedExprs(), IsNil)
+	expected := pc.ExpressionsToPBList(exprs)
+	c.Assert(pc.TracedExprs(), IsNil)
+
+	pc.SetTraceRequestID("req-1")
+	pbExprs := pc.ExpressionsToPBList(exprs)
+	c.Assert(pbExprs[2], IsNil)
+	traced := pc.TracedExprs()
+	c.Assert(traced, HasLen, 2)
+	for i, pbExpr := range pbExprs[:2] {
+		c.Assert(traced[pbExpr], Equals, "req-1")
+		// Tracing doesn't change the converted expressions.
+		c.Assert(pbExpr, DeepEquals, expected[i])
+	}
+}
+
+func (s *testEvaluatorSuite) TestTimestampCompareTimeZone2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = loc
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	tsCol := dg.genColumn(mysql.TypeTimestamp, 0)
+
+	// tsCol = TIMESTAMP '2020-01-01 00:00:00'
+	ts, err := types.ParseTime(sc, "2020-01-01 00:00:00", mysql.TypeTimestamp, 0)
+	c.Assert(err, IsNil)
+	eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), tsCol,
+		&Constant{RetType: types.NewFieldType(mysql.TypeTimestamp), Value: types.NewTimeDatum(ts)})
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQTime)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlTime)
+
+	// The literal in the session time zone is pushed in UTC, which is 5 hours later in winter.
+	utc, err := types.ParseTime(sc, "2020-01-01 05:00:00", mysql.TypeTimestamp, 0)
+	c.Assert(err, IsNil)
+	packed, err := utc.ToPackedUint()
+	c.Assert(err, IsNil)
+	c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeUint(nil, packed))
+	// The constant itself is left in the session time zone.
+	c.Assert(eq.(*ScalarFunction).GetArgs()[1].(*Constant).Value.GetMysqlTime().String(), Equals, "2020-01-01 00:00:00")
+
+	fieldTps := []*types.FieldType{tsCol.RetType}
+	for str, result := range map[string]int64{"2020-01-01 00:00:00": 1, "2020-01-01 05:00:00": 0} {
+		t, err := types.ParseTime(sc, str, mysql.TypeTimestamp, 0)
+		c.Assert(err, IsNil)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewTimeDatum(t)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", str))
+	}
+}
+
+func (s *testEvaluatorSuite) TestInMixedSign2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	signedCol := dg.genColumn(mysql.TypeLonglong, 0)
+	unsignedCol := dg.genColumn(mysql.TypeLonglong, 0)
+	unsignedCol.RetType.Flag |= mysql.UnsignedFlag
+	negOne := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(-1)}
+	two := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(2)}
+	maxUint := &Constant{
+		RetType: &types.FieldType{Tp: mysql.TypeLonglong, Flag: mysql.UnsignedFlag},
+		Value:   types.NewUintDatum(math.MaxUint64),
+	}
+
+	tests := []struct {
+		col    *Column
+		items  []Expression
+		val    types.Datum
+		result int64
+	}{
+		{signedCol, []Expression{negOne, two, maxUint}, types.NewIntDatum(-1), 1},
+		{signedCol, []Expression{negOne, two, maxUint}, types.NewIntDatum(2), 1},
+		// -1 and 18446744073709551615 have the same bits but are different values.
+		{signedCol, []Expression{two, maxUint}, types.NewIntDatum(-1), 0},
+		{unsignedCol, []Expression{negOne, two, maxUint}, types.NewUintDatum(math.MaxUint64), 1},
+		{unsignedCol, []Expression{negOne, two}, types.NewUintDatum(math.MaxUint64), 0},
+	}
+	for i, tt := range tests {
+		in, err := NewFunction(mock.NewContext(), ast.In, types.NewFieldType(mysql.TypeUnspecified), append([]Expression{tt.col}, tt.items...)...)
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{in}, client)
+		c.Assert(pbExpr, NotNil)
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_InInt)
+		for j, item := range tt.items {
+			pbItem := pbExpr.Children[j+1]
+			if item == maxUint {
+				c.Assert(pbItem.Tp, Equals, tipb.ExprType_Uint64)
+				c.Assert(pbItem.Val, DeepEquals, codec.EncodeUint(nil, math.MaxUint64))
+				c.Assert(mysql.HasUnsignedFlag(uint(pbItem.FieldType.Flag)), IsTrue)
+			} else {
+				c.Assert(pbItem.Tp, Equals, tipb.ExprType_Int64)
+				c.Assert(pbItem.Val, DeepEquals, codec.EncodeInt(nil, item.(*Constant).Value.GetInt64()))
+			}
+		}
+
+		d := evalPushedExpr(c, sc, pbExpr, []*types.FieldType{tt.col.RetType}, types.DatumRow{tt.val})
+		c.Assert(d.GetInt64(), Equals, tt.result, Commentf("%d", i))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCast2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	newTp := func(tp byte, flen, decimal int) *types.FieldType {
+		ft := types.NewFieldType(tp)
+		ft.Flen, ft.Decimal = flen, decimal
+		return ft
+	}
+
+	tests := []struct {
+		arg *Column
+		tp  *types.FieldType
+		sig tipb.ScalarFuncSig
+	}{
+		{&Column{RetType: types.NewFieldType(mysql.TypeLonglong)}, newTp(mysql.TypeNewDecimal, 10, 2), tipb.ScalarFuncSig_CastIntAsDecimal},
+		{&Column{RetType: types.NewFieldType(mysql.TypeVarchar)}, newTp(mysql.TypeDatetime, 23, 3), tipb.ScalarFuncSig_CastStringAsTime},
+		{&Column{RetType: types.NewFieldType(mysql.TypeDouble)}, newTp(mysql.TypeLonglong, 20, 0), tipb.ScalarFuncSig_CastRealAsInt},
+	}
+	for _, tt := range tests {
+		fc := BuildCastFunction(ctx, tt.arg, tt.tp)
+		pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+		c.Assert(pbExprs[0], NotNil, Commentf("%v", tt.sig))
+		c.Assert(pbExprs[0].Sig, Equals, tt.sig)
+		// The target type is carried in the field type of the cast.
+		c.Assert(pbExprs[0].FieldType.Tp, Equals, int32(tt.tp.Tp))
+		c.Assert(pbExprs[0].FieldType.Flen, Equals, int32(tt.tp.Flen))
+		c.Assert(pbExprs[0].FieldType.Decimal, Equals, int32(tt.tp.Decimal))
+	}
+
+	// CAST(1.5 AS SIGNED) rounds to 2.
+	realCol := &Column{RetType: types.NewFieldType(mysql.TypeDouble), Index: 0}
+	fc := BuildCastFunction(ctx, realCol, types.NewFieldType(mysql.TypeLonglong))
+	pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+	c.Assert(pbExprs[0], NotNil)
+	d := evalPushedExpr(c, sc, pbExprs[0], []*types.FieldType{realCol.RetType}, types.DatumRow{types.NewFloat64Datum(1.5)})
+	c.Assert(d.GetInt64(), Equals, int64(2))
+}
+
+func (s *testEvaluatorSuite) TestCastStringAsInt2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	// CAST('12abc' AS SIGNED) is 12 with a warning in MySQL.
+	sc.TruncateAsWarning = true
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().StmtCtx = sc
+
+	strTp := types.NewFieldType(mysql.TypeVarchar)
+	strTp.Charset, strTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	strCol := &Column{RetType: strTp, Index: 0}
+	fieldTps := []*types.FieldType{strTp}
+	for _, unsigned := range []bool{false, true} {
+		intTp := types.NewFieldType(mysql.TypeLonglong)
+		if unsigned {
+			intTp.Flag |= mysql.UnsignedFlag
+		}
+		// CAST(strCol AS SIGNED) and CAST(strCol AS UNSIGNED)
+		cast := BuildCastFunction(ctx, strCol, intTp)
+		pbExprs := ExpressionsToPBList(sc, []Expression{cast}, client)
+		c.Assert(pbExprs[0], NotNil)
+		c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_CastStringAsInt)
+		c.Assert(pbExprs[0].FieldType.Tp, Equals, int32(mysql.TypeLonglong))
+		c.Assert(mysql.HasUnsignedFlag(uint(pbExprs[0].FieldType.Flag)), Equals, unsigned)
+
+		// The numeric prefix of the string is parsed, and a string without one is 0.
+		for str, result := range map[string]int64{"12": 12, "12abc": 12, "abc": 0} {
+			d := evalPushedExpr(c, sc, pbExprs[0], fieldTps, types.DatumRow{types.NewStringDatum(str)})
+			c.Assert(d.GetInt64(), Equals, result, Commentf("%s unsigned: %v", str, unsigned))
+		}
+	}
+}
+
+func (s *testEvaluatorSuite) TestCastStringAsDatetimeFsp2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = loc
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().StmtCtx = sc
+
+	// CAST(strCol AS DATETIME(6))
+	strTp := types.NewFieldType(mysql.TypeVarchar)
+	strTp.Charset, strTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	strCol := &Column{RetType: strTp, Index: 0}
+	datetimeTp := types.NewFieldType(mysql.TypeDatetime)
+	datetimeTp.Flen, datetimeTp.Decimal = mysql.MaxDatetimeWidthWithFsp, types.MaxFsp
+	cast := BuildCastFunction(ctx, strCol, datetimeTp)
+	pbExprs := ExpressionsToPBList(sc, []Expression{cast}, client)
+	c.Assert(pbExprs[0], NotNil)
+	c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_CastStringAsTime)
+	c.Assert(pbExprs[0].FieldType.Tp, Equals, int32(mysql.TypeDatetime))
+	c.Assert(pbExprs[0].FieldType.Decimal, Equals, int32(6))
+
+	// The microseconds are kept, and a DATETIME is not converted by the session time zone.
+	d := evalPushedExpr(c, sc, pbExprs[0], []*types.FieldType{strTp}, types.DatumRow{types.NewStringDatum("2020-01-01 12:34:56.123456")})
+	c.Assert(d.GetMysqlTime().Type, Equals, mysql.TypeDatetime)
+	c.Assert(d.GetMysqlTime().Fsp, Equals, 6)
+	c.Assert(d.GetMysqlTime().String(), Equals, "2020-01-01 12:34:56.123456")
+}
+
+func (s *testEvaluatorSuite) TestEnumConstant2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+
+	enumTp := types.NewFieldType(mysql.TypeEnum)
+	enumTp.Elems = []string{"inactive", "active"}
+	enumTp.Charset, enumTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	enum, err := types.ParseEnumName(enumTp.Elems, "active")
+	c.Assert(err, IsNil)
+	var d types.Datum
+	d.SetMysqlEnum(enum)
+	con := &Constant{RetType: enumTp, Value: d}
+
+	strTp := types.NewFieldType(mysql.TypeVarchar)
+	strTp.Charset, strTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	strCol := &Column{RetType: strTp, Index: 0}
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), strCol, con)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	// The enum constant is pushed down as its name.
+	conPB := pbExpr.Children[1]
+	c.Assert(conPB.Tp, Equals, tipb.ExprType_String)
+	c.Assert(string(conPB.Val), Equals, "active")
+	c.Assert(conPB.FieldType.Tp, Equals, int32(mysql.TypeVarString))
+
+	for val, result := range map[string]int64{"active": 1, "inactive": 0} {
+		d := evalPushedExpr(c, sc, pbExpr, []*types.FieldType{strTp}, types.DatumRow{types.NewStringDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", val))
+	}
+
+	// Enum columns still can't be pushed down.
+	enumCol := &Column{RetType: enumTp, Index: 0}
+	eq, err = NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), enumCol, con)
+	c.Assert(err, IsNil)
+	_, pushed, remained = ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+}
+
+func (s *testEvaluatorSuite) TestSet2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+
+	setTp := types.NewFieldType(mysql.TypeSet)
+	setTp.Elems = []string{"a", "b", "c"}
+	setTp.Charset, setTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	set, err := types.ParseSetName(setTp.Elems, "a,c")
+	c.Assert(err, IsNil)
+	var d types.Datum
+	d.SetMysqlSet(set)
+	con := &Constant{RetType: setTp, Value: d}
+
+	// The set constant is pushed down as its name.
+	strTp := types.NewFieldType(mysql.TypeVarchar)
+	strTp.Charset, strTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	strCol := &Column{RetType: strTp, Index: 0}
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), strCol, con)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_String)
+	c.Assert(string(pbExpr.Children[1].Val), Equals, "a,c")
+	c.Assert(pbExpr.Children[1].FieldType.Tp, Equals, int32(mysql.TypeVarString))
+	for val, result := range map[string]int64{"a,c": 1, "a": 0} {
+		d := evalPushedExpr(c, sc, pbExpr, []*types.FieldType{strTp}, types.DatumRow{types.NewStringDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%s", val))
+	}
+
+	// Set columns are only pushed down if the client can decode them.
+	setCol := &Column{RetType: setTp, Index: 0}
+	eq, err = NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), setCol, con)
+	c.Assert(err, IsNil)
+	_, pushed, remained = ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+
+	setClient := &capabilityClient{supported: map[tipb.ExprType]bool{tipb.ExprType(kv.ReqSubTypeSetColumn): true}}
+	pbExpr, pushed, remained = ExpressionsToPB(sc, []Expression{eq}, setClient)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Children[0].Tp, Equals, tipb.ExprType_ColumnRef)
+	c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(mysql.TypeSet))
+}
+
+func (s *testEvaluatorSuite) TestNullEQTemporal2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = loc
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().StmtCtx = sc
+
+	// The string literal is folded into a DATETIME constant, which is not
+	// converted to UTC even if it's compared with a timestamp column.
+	literal, err := types.ParseTime(sc, "2020-01-01", mysql.TypeDatetime, types.MaxFsp)
+	c.Assert(err, IsNil)
+	packed, err := literal.ToPackedUint()
+	c.Assert(err, IsNil)
+
+	for _, tp := range []byte{mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp} {
+		col := dg.genColumn(tp, 0)
+		fieldTps := []*types.FieldType{col.RetType}
+		t, err := types.ParseTime(sc, "2020-01-01", tp, 0)
+		c.Assert(err, IsNil)
+
+		// col <=> NULL
+		nullEQ, err := NewFunction(ctx, ast.NullEQ, types.NewFieldType(mysql.TypeUnspecified), col, Null.Clone())
+		c.Assert(err, IsNil)
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{nullEQ}, client)
+		c.Assert(pbExpr, NotNil, Commentf("%d", tp))
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_NullEQTime)
+		c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(tp))
+		c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_Null)
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{{}})
+		c.Assert(d.GetInt64(), Equals, int64(1))
+		d = evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewTimeDatum(t)})
+		c.Assert(d.GetInt64(), Equals, int64(0))
+
+		// col <=> '2020-01-01'
+		str := &Constant{RetType: types.NewFieldType(mysql.TypeVarString), Value: types.NewDatum("2020-01-01")}
+		nullEQ, err = NewFunction(ctx, ast.NullEQ, types.NewFieldType(mysql.TypeUnspecified), col, str)
+		c.Assert(err, IsNil)
+		pbExpr, pushed, remained = ExpressionsToPB(sc, []Expression{nullEQ}, client)
+		c.Assert(pbExpr, NotNil, Commentf("%d", tp))
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_NullEQTime)
+		c.Assert(pbExpr.Children[0].FieldType.Tp, Equals, int32(tp))
+		c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlTime)
+		c.Assert(pbExpr.Children[1].FieldType.Tp, Equals, int32(mysql.TypeDatetime))
+		c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeUint(nil, packed))
+		d = evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewTimeDatum(t)})
+		c.Assert(d.GetInt64(), Equals, int64(1), Commentf("%d", tp))
+		d = evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{{}})
+		c.Assert(d.GetInt64(), Equals, int64(0), Commentf("%d", tp))
+	}
+}
+
+func (s *testEvaluatorSuite) TestSplitRangeAndFilterPB(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	newCol := func(pos int) *Column {
+		return &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Position: pos, Index: pos}
+	}
+	a, b, col := newCol(0), newCol(1), newCol(2)
+	newFunc := func(funcName string, args ...Expression) Expression {
+		fc, err := NewFunction(ctx, funcName, types.NewFieldType(mysql.TypeUnspecified), args...)
+		c.Assert(err, IsNil)
+		return fc
+	}
+
+	// a = 5 and b > 10 and abs(c) < 1 and sign(c) < 1 over the index (a, b).
+	eq := newFunc(ast.EQ, a, newLonglong(5))
+	gt := newFunc(ast.GT, b, newLonglong(10))
+	absLT := newFunc(ast.LT, newFunc(ast.Abs, col), newLonglong(1))
+	signLT := newFunc(ast.LT, newFunc(ast.Sign, col), newLonglong(1))
+	accessConds, filter, pushed, remained := SplitRangeAndFilterPB(sc, []Expression{eq, gt, absLT, signLT}, []*Column{a, b}, client)
+	c.Assert(accessConds, DeepEquals, []Expression{eq, gt})
+	c.Assert(pushed, DeepEquals, []Expression{absLT})
+	c.Assert(remained, DeepEquals, []Expression{signLT})
+	c.Assert(filter, NotNil)
+	c.Assert(filter.Sig, Equals, tipb.ScalarFuncSig_LTInt)
+	c.Assert(filter.Children[0].Sig, Equals, tipb.ScalarFuncSig_AbsInt)
+
+	// Comparisons with the constant on the left and IN lists are range-convertible too,
+	// but not the ones over columns out of the index.
+	lt := newFunc(ast.LT, newLonglong(1), b)
+	in := newFunc(ast.In, a, newLonglong(1), newLonglong(2))
+	colEQ := newFunc(ast.EQ, col, newLonglong(1))
+	accessConds, filter, pushed, remained = SplitRangeAndFilterPB(sc, []Expression{lt, in, colEQ}, []*Column{a, b}, client)
+	c.Assert(accessConds, DeepEquals, []Expression{lt, in})
+	c.Assert(pushed, DeepEquals, []Expression{colEQ})
+	c.Assert(remained, HasLen, 0)
+	c.Assert(filter.Sig, Equals, tipb.ScalarFuncSig_EQInt)
+}
+
+func (s *testEvaluatorSuite) TestJSONConstant2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	dg := new(dataGen4Expr2PbTest)
+
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	strTp.Flag |= mysql.ParseToJSONFlag
+	str := &Constant{RetType: strTp, Value: types.NewDatum(`{"a": [1, "b"]}`)}
+	con, ok := FoldConstant(BuildCastFunction(ctx, str, types.NewFieldType(mysql.TypeJSON))).(*Constant)
+	c.Assert(ok, IsTrue)
+	j := con.Value.GetMysqlJSON()
+	c.Assert(j.String(), Equals, `{"a":[1,"b"]}`)
+
+	// The constant is encoded as the type code followed by the binary value.
+	pbExprs := ExpressionsToPBList(sc, []Expression{con}, client)
+	c.Assert(pbExprs[0], NotNil)
+	c.Assert(pbExprs[0].Tp, Equals, tipb.ExprType_MysqlJson)
+	c.Assert(pbExprs[0].Val, DeepEquals, append([]byte{j.TypeCode}, j.Value...))
+	c.Assert(pbExprs[0].FieldType.Tp, Equals, int32(mysql.TypeJSON))
+
+	// jsonCol = JSON literal
+	jsonCol := dg.genColumn(mysql.TypeJSON, 0)
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), jsonCol, con)
+	c.Assert(err, IsNil)
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQJson)
+	d := evalPushedExpr(c, sc, pbExpr, []*types.FieldType{jsonCol.RetType}, types.DatumRow{con.Value})
+	c.Assert(d.GetInt64(), Equals, int64(1))
+
+	// It's not pushed down if the client can't decode JSON constants.
+	noJSONClient := &capabilityClient{supported: map[tipb.ExprType]bool{tipb.ExprType_MysqlJson: false}}
+	_, pushed, remained = ExpressionsToPB(sc, []Expression{eq}, noJSONClient)
+	c.Assert(pushed, HasLen, 0)
+	c.Assert(remained, HasLen, 1)
+}
+
+func (s *testEvaluatorSuite) TestCastAsDecimalRound2Pb(c *C) {
+	// Rounding is reported as truncation, which is a warning for CAST.
+	sc := &stmtctx.StatementContext{TruncateAsWarning: true}
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().StmtCtx = sc
+	decTp := types.NewFieldType(mysql.TypeNewDecimal)
+	decTp.Flen, decTp.Decimal = 10, 2
+
+	tests := []struct {
+		arg    types.Datum
+		argTp  byte
+		result string
+	}{
+		{types.NewDecimalDatum(types.NewDecFromStringForTest("2.345")), mysql.TypeNewDecimal, "2.35"},
+		{types.NewDecimalDatum(types.NewDecFromStringForTest("-2.345")), mysql.TypeNewDecimal, "-2.35"},
+		{types.NewDecimalDatum(types.NewDecFromStringForTest("2.344")), mysql.TypeNewDecimal, "2.34"},
+		{types.NewFloat64Datum(2.345), mysql.TypeDouble, "2.35"},
+		{types.NewFloat64Datum(-2.345), mysql.TypeDouble, "-2.35"},
+		{types.NewStringDatum("2.345"), mysql.TypeVarString, "2.35"},
+	}
+	for _, tt := range tests {
+		col := &Column{RetType: types.NewFieldType(tt.argTp), Index: 0}
+		fc := BuildCastFunction(ctx, col, decTp)
+		pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+		c.Assert(pbExprs[0], NotNil)
+		c.Assert(pbExprs[0].FieldType.Flen, Equals, int32(10))
+		c.Assert(pbExprs[0].FieldType.Decimal, Equals, int32(2))
+
+		row := types.DatumRow{tt.arg}
+		expected, err := fc.Eval(row)
+		c.Assert(err, IsNil)
+		c.Assert(expected.GetMysqlDecimal().String(), Equals, tt.result, Commentf("%v", tt.arg))
+		d := evalPushedExpr(c, sc, pbExprs[0], []*types.FieldType{col.RetType}, row)
+		c.Assert(d.GetMysqlDecimal().String(), Equals, tt.result, Commentf("%v", tt.arg))
+	}
+}
+
+func (s *testEvaluatorSuite) TestShortCircuitOr2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	a := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	b := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 1}
+
+	// a = 1 OR 1/b = 2
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), a, newLonglong(1))
+	c.Assert(err, IsNil)
+	div, err := NewFunction(ctx, ast.Div, types.NewFieldType(mysql.TypeUnspecified), newLonglong(1), b)
+	c.Assert(err, IsNil)
+	divEQ, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), div, newLonglong(2))
+	c.Assert(err, IsNil)
+	or, err := NewFunction(ctx, ast.LogicOr, types.NewFieldType(mysql.TypeUnspecified), eq, divEQ)
+	c.Assert(err, IsNil)
+
+	// The disjuncts are pushed down in order and evaluated with short circuit like MySQL,
+	// and the statement flags decide whether dividing by zero is an error on the client.
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{or}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_LogicalOr)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_EQInt)
+	c.Assert(pbExpr.Children[1].Children[0].Sig, Equals, tipb.ScalarFuncSig_DivideDecimal)
+
+	fieldTps := []*types.FieldType{a.RetType, b.RetType}
+	d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(1), types.NewIntDatum(0)})
+	c.Assert(d.GetInt64(), Equals, int64(1))
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
+	d = evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(2), types.NewIntDatum(0)})
+	c.Assert(d.IsNull(), IsTrue)
+	c.Assert(sc.WarningCount(), Equals, uint16(1))
+
+	// Rebalancing a longer chain keeps the disjuncts in order.
+	pc := NewPBConverter(client, sc)
+	pc.SetRebalanceAssociative(true)
+	or3, err := NewFunction(ctx, ast.LogicOr, types.NewFieldType(mysql.TypeUnspecified), or, eq)
+	c.Assert(err, IsNil)
+	pbExpr = pc.ExprToPB(or3)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_EQInt)
+	c.Assert(pbExpr.Children[1].Sig, Equals, tipb.ScalarFuncSig_LogicalOr)
+	c.Assert(pbExpr.Children[1].Children[0].Children[0].Sig, Equals, tipb.ScalarFuncSig_DivideDecimal)
+	d = evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(1), types.NewIntDatum(0)})
+	c.Assert(d.GetInt64(), Equals, int64(1))
+	c.Assert(sc.WarningCount(), Equals, uint16(1))
+}
+
+func (s *testEvaluatorSuite) TestRegisterPushableFunc(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	neg, err := NewFunction(mock.NewContext(), ast.UnaryMinus, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+	abs, err := NewFunction(mock.NewContext(), ast.Abs, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+
+	pbExprs := ExpressionsToPBList(sc, []Expression{neg, abs}, client)
+	c.Assert(pbExprs[0], IsNil)
+	c.Assert(pbExprs[1], NotNil)
+
+	RegisterPushableFunc(strings.ToUpper(ast.UnaryMinus))
+	UnregisterPushableFunc(ast.Abs)
+	defer func() {
+		UnregisterPushableFunc(ast.UnaryMinus)
+		RegisterPushableFunc(ast.Abs)
+	}()
+	pbExprs = ExpressionsToPBList(sc, []Expression{neg, abs}, client)
+	c.Assert(pbExprs[0], NotNil)
+	c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_UnaryMinusInt)
+	c.Assert(pbExprs[1], IsNil)
+
+	// Functions without pb codes are not pushed down even if they are registered.
+	sign, err := NewFunction(mock.NewContext(), ast.Sign, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+	RegisterPushableFunc(ast.Sign)
+	defer UnregisterPushableFunc(ast.Sign)
+	c.Assert(ExpressionsToPBList(sc, []Expression{sign}, client)[0], IsNil)
+}
+
+func (s *testEvaluatorSuite) TestRefuseEnumSetResults2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+
+	enumTp := types.NewFieldType(mysql.TypeEnum)
+	enumTp.Elems = []string{"inactive", "active"}
+	enumTp.Charset, enumTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	newEnum := func(name string) *Constant {
+		enum, err := types.ParseEnumName(enumTp.Elems, name)
+		c.Assert(err, IsNil)
+		var d types.Datum
+		d.SetMysqlEnum(enum)
+		return &Constant{RetType: enumTp, Value: d}
+	}
+
+	// CASE WHEN col > 1 THEN 'active' ELSE 'inactive' END
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	gt, err := NewFunction(ctx, ast.GT, types.NewFieldType(mysql.TypeUnspecified), col, One)
+	c.Assert(err, IsNil)
+	caseWhen, err := NewFunction(ctx, ast.Case, types.NewFieldType(mysql.TypeUnspecified), gt, newEnum("active"), newEnum("inactive"))
+	c.Assert(err, IsNil)
+	// TiDB infers a string type for CASE over enums, so make it return an enum.
+	c.Assert(caseWhen.GetType().Tp, Equals, mysql.TypeVarchar)
+	caseWhen.(*ScalarFunction).RetType = enumTp
+
+	pc := NewPBConverter(client, sc)
+	c.Assert(pc.ExprToPB(caseWhen), NotNil)
+	pc.SetRefuseEnumSetResults(true)
+	c.Assert(pc.ExprToPB(caseWhen), IsNil)
+	// Functions returning other types are still pushed down.
+	c.Assert(pc.ExprToPB(gt), NotNil)
+}
+
+func (s *testEvaluatorSuite) TestExprToPBWithReason(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	newFunc := func(funcName string, args ...Expression) Expression {
+		fc, err := NewFunction(ctx, funcName, types.NewFieldType(mysql.TypeUnspecified), args...)
+		c.Assert(err, IsNil)
+		return fc
+	}
+	sign := newFunc(ast.Sign, col)
+	abs := newFunc(ast.Abs, col)
+	now, err := types.ParseTime(sc, "2020-01-01 00:00:00", mysql.TypeDatetime, 0)
+	c.Assert(err, IsNil)
+	timeCon := &Constant{RetType: types.NewFieldType(mysql.TypeDatetime), Value: types.NewTimeDatum(now)}
+
+	pc := NewPBConverter(client, sc)
+	pbExpr, reason := pc.ExprToPBWithReason(abs)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(reason, Equals, "")
+
+	tests := []struct {
+		expr   Expression
+		reason string
+	}{
+		{sign, "function sign not whitelisted"},
+		// The reason of the innermost expression which can't be converted is returned.
+		{newFunc(ast.GT, sign, One), "function sign not whitelisted"},
+		{&Column{RetType: types.NewFieldType(mysql.TypeBit), Index: 0}, "unsupported column type bit"},
+		{&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.MinNotNullDatum()}, "unsupported constant kind 15"},
+	}
+	for _, tt := range tests {
+		pbExpr, reason := pc.ExprToPBWithReason(tt.expr)
+		c.Assert(pbExpr, IsNil)
+		c.Assert(reason, Equals, tt.reason, Commentf("%s", tt.expr))
+	}
+
+	noTimeClient := &capabilityClient{supported: map[tipb.ExprType]bool{tipb.ExprType_MysqlTime: false}}
+	noTimePC := NewPBConverter(noTimeClient, sc)
+	_, reason = noTimePC.ExprToPBWithReason(timeCon)
+	c.Assert(reason, Equals, "request type not supported by client: MysqlTime")
+
+	pc.SetPushdownBlacklist(map[string]struct{}{ast.Abs: {}})
+	_, reason = pc.ExprToPBWithReason(abs)
+	c.Assert(reason, Equals, "function abs blacklisted")
+
+	RegisterPushableFunc(ast.Sign)
+	defer UnregisterPushableFunc(ast.Sign)
+	_, reason = pc.ExprToPBWithReason(sign)
+	c.Assert(reason, Equals, "function sign has no pb signature")
+
+	// ExpressionsToPBWithReasons explains every remained expression.
+	_, pushed, remained, reasons := ExpressionsToPBWithReasons(sc, []Expression{abs, newFunc(ast.Mod, col, One), sign}, client)
+	c.Assert(pushed, DeepEquals, []Expression{abs})
+	c.Assert(remained, HasLen, 2)
+	c.Assert(reasons, DeepEquals, []string{"function mod not whitelisted", "function sign has no pb signature"})
+}
+
+func (s *testEvaluatorSuite) TestTrailingSpaceCompare2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+
+	colTp := types.NewFieldType(mysql.TypeVarchar)
+	colTp.Charset, colTp.Collate = charset.CharsetUTF8, charset.CollationUTF8
+	col := &Column{RetType: colTp, Index: 0}
+	con := &Constant{RetType: colTp, Value: types.NewDatum("abc")}
+	eq, err := NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeUnspecified), col, con)
+	c.Assert(err, IsNil)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	// The collation of the arguments is carried in their field types.
+	for _, child := range pbExpr.Children {
+		c.Assert(child.FieldType.Collate, Equals, int32(mysql.CollationNames[charset.CollationUTF8]))
+	}
+
+	// The pushed comparison agrees with TiDB, which compares the bytes of strings with binary
+	// collations and doesn't ignore trailing spaces as the PAD SPACE collations of MySQL do yet.
+	fieldTps := []*types.FieldType{colTp}
+	for val, result := range map[string]int64{"abc": 1, "abc ": 0, "abc  ": 0, " abc": 0} {
+		row := types.DatumRow{types.NewStringDatum(val)}
+		expected, err := eq.Eval(row)
+		c.Assert(err, IsNil)
+		c.Assert(expected.GetInt64(), Equals, result, Commentf("%q", val))
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, row)
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%q", val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestPushdownMetrics(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	abs, err := NewFunction(mock.NewContext(), ast.Abs, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+	sign, err := NewFunction(mock.NewContext(), ast.Sign, types.NewFieldType(mysql.TypeUnspecified), col)
+	c.Assert(err, IsNil)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.ExpressionPushdownPushedCounter, metrics.ExpressionPushdownRemainedCounter)
+	counterValue := func(name, funcName string) float64 {
+		mfs, err := reg.Gather()
+		c.Assert(err, IsNil)
+		for _, mf := range mfs {
+			if mf.GetName() != name {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				if m.GetLabel()[0].GetValue() == funcName {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+	const pushedName, remainedName = "tidb_expression_pushdown_pushed_total", "tidb_expression_pushdown_remained_total"
+	pushedAbs, remainedAbs := counterValue(pushedName, ast.Abs), counterValue(remainedName, ast.Abs)
+	remainedSign := counterValue(remainedName, ast.Sign)
+
+	ExpressionsToPB(sc, []Expression{abs, sign, col}, client)
+	c.Assert(counterValue(pushedName, ast.Abs), Equals, pushedAbs+1)
+	c.Assert(counterValue(remainedName, ast.Sign), Equals, remainedSign+1)
+	ExpressionsToPBList(sc, []Expression{abs, sign}, client)
+	c.Assert(counterValue(pushedName, ast.Abs), Equals, pushedAbs+2)
+	c.Assert(counterValue(remainedName, ast.Sign), Equals, remainedSign+2)
+	c.Assert(counterValue(remainedName, ast.Abs), Equals, remainedAbs)
+
+	// The counters are registered to the default registry on the first use.
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	c.Assert(err, IsNil)
+	registered := make(map[string]bool)
+	for _, mf := range mfs {
+		registered[mf.GetName()] = true
+	}
+	c.Assert(registered[pushedName], IsTrue)
+	c.Assert(registered[remainedName], IsTrue)
+}
+
+func (s *testEvaluatorSuite) TestPBConverterReset(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	con := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(1)}
+
+	pc := NewPBConverter(client, sc)
+	pbExpr := pc.ExprToPB(con)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pbExpr.Val, DeepEquals, codec.EncodeInt(nil, 1))
+	// The value of the constant is cached until pc is reset.
+	con.Value = types.NewIntDatum(2)
+	pbExpr = pc.ExprToPB(con)
+	c.Assert(pbExpr.Val, DeepEquals, codec.EncodeInt(nil, 1))
+	pc.Reset()
+	pbExpr = pc.ExprToPB(con)
+	c.Assert(pbExpr.Val, DeepEquals, codec.EncodeInt(nil, 2))
+
+	// The pooled converters don't share the cached values between calls.
+	pbExprs := ExpressionsToPBList(sc, []Expression{con}, client)
+	c.Assert(pbExprs[0].Val, DeepEquals, codec.EncodeInt(nil, 2))
+	con.Value = types.NewIntDatum(3)
+	pbExprs = ExpressionsToPBList(sc, []Expression{con}, client)
+	c.Assert(pbExprs[0].Val, DeepEquals, codec.EncodeInt(nil, 3))
+}
+
+func (s *testEvaluatorSuite) TestCastJSONExtractAsDatetime2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = loc
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().StmtCtx = sc
+
+	// CAST(JSON_UNQUOTE(JSON_EXTRACT(doc, '$.ts')) AS DATETIME)
+	doc := dg.genColumn(mysql.TypeJSON, 0)
+	extract, err := NewFunction(ctx, ast.JSONExtract, types.NewFieldType(mysql.TypeUnspecified), doc,
+		&Constant{RetType: types.NewFieldType(mysql.TypeString), Value: types.NewDatum("$.ts")})
+	c.Assert(err, IsNil)
+	unquote, err := NewFunction(ctx, ast.JSONUnquote, types.NewFieldType(mysql.TypeUnspecified), extract)
+	c.Assert(err, IsNil)
+	datetimeTp := types.NewFieldType(mysql.TypeDatetime)
+	datetimeTp.Flen, datetimeTp.Decimal = mysql.MaxDatetimeWidthNoFsp, 0
+	cast := BuildCastFunction(ctx, unquote, datetimeTp)
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{cast}, client)
+	c.Assert(pbExpr, NotNil)
+	c.Assert(pushed, HasLen, 1)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_CastStringAsTime)
+	c.Assert(pbExpr.FieldType.Tp, Equals, int32(mysql.TypeDatetime))
+	c.Assert(pbExpr.Children[0].Sig, Equals, tipb.ScalarFuncSig_JsonUnquoteSig)
+	c.Assert(pbExpr.Children[0].Children[0].Sig, Equals, tipb.ScalarFuncSig_JsonExtractSig)
+
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	strTp.Flag |= mysql.ParseToJSONFlag
+	str := &Constant{RetType: strTp, Value: types.NewDatum(`{"ts": "2020-01-01 12:34:56"}`)}
+	j := FoldConstant(BuildCastFunction(ctx, str, types.NewFieldType(mysql.TypeJSON))).(*Constant).Value
+	row := types.DatumRow{j}
+	fieldTps := []*types.FieldType{doc.RetType}
+	// A DATETIME doesn't carry a time zone, so the result is the same in every session time zone.
+	for _, tz := range []*time.Location{loc, time.UTC} {
+		sc.TimeZone = tz
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, row)
+		c.Assert(d.Kind(), Equals, types.KindMysqlTime)
+		c.Assert(d.GetMysqlTime().Type, Equals, mysql.TypeDatetime)
+		c.Assert(d.GetMysqlTime().String(), Equals, "2020-01-01 12:34:56", Commentf("%v", tz))
+	}
+}
+
+func (s *testEvaluatorSuite) TestBalancedAnd2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+	col := dg.genColumn(mysql.TypeLonglong, 0)
+
+	var andDepth func(*tipb.Expr) int
+	andDepth = func(pbExpr *tipb.Expr) int {
+		if pbExpr.Tp != tipb.ExprType_And {
+			return 0
+		}
+		left, right := andDepth(pbExpr.Children[0]), andDepth(pbExpr.Children[1])
+		if left > right {
+			return left + 1
+		}
+		return right + 1
+	}
+	// col > 0, col > 1, ..., col > 7
+	conds := make([]Expression, 0, 8)
+	for i := 0; i < 8; i++ {
+		gt, err := NewFunction(mock.NewContext(), ast.GT, types.NewFieldType(mysql.TypeUnspecified), col,
+			&Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(int64(i))})
+		c.Assert(err, IsNil)
+		conds = append(conds, gt)
+	}
+
+	pbExpr, pushed, remained := ExpressionsToPB(sc, conds, client)
+	c.Assert(pushed, HasLen, 8)
+	c.Assert(remained, HasLen, 0)
+	c.Assert(andDepth(pbExpr), Equals, 3)
+	fieldTps := []*types.FieldType{col.RetType}
+	for val, result := range map[int64]int64{1: 0, 7: 0, 8: 1} {
+		d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewIntDatum(val)})
+		c.Assert(d.GetInt64(), Equals, result, Commentf("%d", val))
+	}
+
+	// A single predicate is not wrapped in an AND.
+	pbExpr, _, _ = ExpressionsToPB(sc, conds[:1], client)
+	c.Assert(pbExpr.Tp, Equals, tipb.ExprType_ScalarFunc)
+	c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_GTInt)
+}
+
+func (s *testEvaluatorSuite) TestDeferredConstantTimeZone2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	ctx := mock.NewContext()
+	sc := ctx.GetSessionVars().StmtCtx
+	sc.TimeZone = loc
+	client := new(mock.Client)
+
+	// FROM_UNIXTIME(?) with the parameter 0 is folded into a deferred constant,
+	// which is evaluated again when it's converted.
+	zero := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.NewIntDatum(0)}
+	param := &Constant{RetType: zero.RetType, Value: zero.Value, DeferredExpr: zero}
+	con, err := NewFunction(ctx, ast.FromUnixTime, types.NewFieldType(mysql.TypeDatetime), param)
+	c.Assert(err, IsNil)
+	c.Assert(con.(*Constant).DeferredExpr, NotNil)
+
+	for tz, str := range map[*time.Location]string{loc: "1969-12-31 19:00:00", time.UTC: "1970-01-01 00:00:00"} {
+		sc.TimeZone = tz
+		pbExprs := ExpressionsToPBList(sc, []Expression{con}, client)
+		c.Assert(pbExprs[0], NotNil)
+		c.Assert(pbExprs[0].Tp, Equals, tipb.ExprType_MysqlTime)
+		t, err := types.ParseTime(sc, str, mysql.TypeDatetime, 0)
+		c.Assert(err, IsNil)
+		packed, err := t.ToPackedUint()
+		c.Assert(err, IsNil)
+		c.Assert(pbExprs[0].Val, DeepEquals, codec.EncodeUint(nil, packed), Commentf("%v", tz))
+	}
+}
+
+func (s *testEvaluatorSuite) TestIfNullBlob2Pb(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+
+	blobTp := types.NewFieldType(mysql.TypeBlob)
+	blobTp.Flen = mysql.MaxBlobWidth
+	types.SetBinChsClnFlag(blobTp)
+	blobCol := &Column{RetType: blobTp, Index: 0}
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	strTp.Charset, strTp.Collate, strTp.Flen = charset.CharsetUTF8, charset.CollationUTF8, 0
+	empty := &Constant{RetType: strTp, Value: types.NewStringDatum("")}
+
+	// IFNULL(blobCol, '')
+	fc, err := NewFunction(mock.NewContext(), ast.Ifnull, types.NewFieldType(mysql.TypeUnspecified), blobCol, empty)
+	c.Assert(err, IsNil)
+	pbExprs := ExpressionsToPBList(sc, []Expression{fc}, client)
+	c.Assert(pbExprs[0], NotNil)
+	c.Assert(pbExprs[0].Sig, Equals, tipb.ScalarFuncSig_IfNullString)
+	// The result is unified to a binary BLOB wide enough for the column, not to a narrower string.
+	ft := pbExprs[0].FieldType
+	c.Assert(ft.Tp, Equals, int32(mysql.TypeLongBlob))
+	c.Assert(uint(ft.Flag)&mysql.BinaryFlag, Equals, uint(mysql.BinaryFlag))
+	c.Assert(ft.Charset, Equals, charset.CharsetBin)
+	c.Assert(ft.Flen >= int32(blobTp.Flen), IsTrue)
+
+	fieldTps := []*types.FieldType{blobTp}
+	d := evalPushedExpr(c, sc, pbExprs[0], fieldTps, types.DatumRow{types.NewDatum(nil)})
+	c.Assert(d.GetBytes(), HasLen, 0)
+	d = evalPushedExpr(c, sc, pbExprs[0], fieldTps, types.DatumRow{types.NewBytesDatum([]byte{0, 0xff})})
+	c.Assert(d.GetBytes(), DeepEquals, []byte{0, 0xff})
+}
+
+func (s *testEvaluatorSuite) TestDurationConstantTimeZone2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	client := new(mock.Client)
+	dur, err := types.ParseDuration("-12:34:56.5", 1)
+	c.Assert(err, IsNil)
+	durTp := types.NewFieldType(mysql.TypeDuration)
+	durTp.Decimal = 1
+	con := &Constant{RetType: durTp, Value: types.NewDurationDatum(dur)}
+
+	// -(12h 34m 56.5s) in nanoseconds, whatever the session time zone is.
+	const nanos int64 = -45296500000000
+	for _, tz := range []*time.Location{time.UTC, loc} {
+		sc := new(stmtctx.StatementContext)
+		sc.TimeZone = tz
+		pbExprs := ExpressionsToPBList(sc, []Expression{con}, client)
+		c.Assert(pbExprs[0], NotNil)
+		c.Assert(pbExprs[0].Tp, Equals, tipb.ExprType_MysqlDuration)
+		c.Assert(pbExprs[0].Val, DeepEquals, codec.EncodeInt(nil, nanos), Commentf("%v", tz))
+	}
+}
+
+func (s *testEvaluatorSuite) TestConstantToPBError(c *C) {
+	sc := new(stmtctx.StatementContext)
+	client := new(mock.Client)
+	ctx := mock.NewContext()
+	pc := NewPBConverter(client, sc)
+
+	// LOWER(?) with the parameter 'abc' is folded into a deferred constant, whose value
+	// can't be converted to an integer.
+	abc := &Constant{RetType: types.NewFieldType(mysql.TypeVarString), Value: types.NewStringDatum("abc")}
+	param := &Constant{RetType: abc.RetType, Value: abc.Value, DeferredExpr: abc}
+	lower, err := NewFunction(ctx, ast.Lower, types.NewFieldType(mysql.TypeVarString), param)
+	c.Assert(err, IsNil)
+	broken := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), DeferredExpr: lower.(*Constant).DeferredExpr}
+	pbExpr, err := pc.constantToPBExprE(broken)
+	c.Assert(err, NotNil)
+	c.Assert(pbExpr, IsNil)
+	pbExpr, reason := pc.ExprToPBWithReason(broken)
+	c.Assert(pbExpr, IsNil)
+	c.Assert(strings.HasPrefix(reason, "fail to convert constant"), IsTrue, Commentf("%s", reason))
+
+	// A valid constant of a kind which can't be pushed down is not an error.
+	unsupported := &Constant{RetType: types.NewFieldType(mysql.TypeLonglong), Value: types.MinNotNullDatum()}
+	pbExpr, err = pc.constantToPBExprE(unsupported)
+	c.Assert(err, IsNil)
+	c.Assert(pbExpr, IsNil)
+}
+
+func (s *testEvaluatorSuite) TestZeroAndMaxDatetimeCompare2Pb(c *C) {
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	sc := new(stmtctx.StatementContext)
+	sc.TimeZone = loc
+	client := new(mock.Client)
+	dg := new(dataGen4Expr2PbTest)
+
+	tests := []struct {
+		tp      byte
+		literal string
+		other   string
+	}{
+		// The zero timestamp is kept zero rather than converted to UTC.
+		{mysql.TypeTimestamp, "0000-00-00 00:00:00", "2020-01-01 00:00:00"},
+		{mysql.TypeDatetime, "0000-00-00 00:00:00", "2020-01-01 00:00:00"},
+		{mysql.TypeDatetime, "9999-12-31 23:59:59", "9999-12-31 23:59:58"},
+	}
+	for _, tt := range tests {
+		col := dg.genColumn(tt.tp, 0)
+		t, err := types.ParseTime(sc, tt.literal, tt.tp, 0)
+		c.Assert(err, IsNil)
+		eq, err := NewFunction(mock.NewContext(), ast.EQ, types.NewFieldType(mysql.TypeUnspecified), col,
+			&Constant{RetType: types.NewFieldType(tt.tp), Value: types.NewTimeDatum(t)})
+		c.Assert(err, IsNil)
+
+		pbExpr, pushed, remained := ExpressionsToPB(sc, []Expression{eq}, client)
+		c.Assert(pbExpr, NotNil, Commentf("%s", tt.literal))
+		c.Assert(pushed, HasLen, 1)
+		c.Assert(remained, HasLen, 0)
+		c.Assert(pbExpr.Sig, Equals, tipb.ScalarFuncSig_EQTime)
+		c.Assert(pbExpr.Children[1].Tp, Equals, tipb.ExprType_MysqlTime)
+		packed, err := t.ToPackedUint()
+		c.Assert(err, IsNil)
+		c.Assert(pbExpr.Children[1].Val, DeepEquals, codec.EncodeUint(nil, packed), Commentf("%s", tt.literal))
+
+		fieldTps := []*types.FieldType{col.RetType}
+		for str, result := range map[string]int64{tt.literal: 1, tt.other: 0} {
+			val, err := types.ParseTime(sc, str, tt.tp, 0)
+			c.Assert(err, IsNil)
+			d := evalPushedExpr(c, sc, pbExpr, fieldTps, types.DatumRow{types.NewTimeDatum(val)})
+			c.Assert(d.GetInt64(), Equals, result, Commentf("%s = %s", tt.literal, str))
+		}
+	}
+}
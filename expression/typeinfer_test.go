@@ -990,6 +990,14 @@ func (s *testInferTypeSuite) createTestCase4CompareFuncs() []typeInferTestCase {
 		{"coalesce(NULL, c_int_d)", mysql.TypeLong, charset.CharsetBin, mysql.BinaryFlag, 11, 0},
 		{"coalesce(c_int_d, c_decimal)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 15, 3},
 		{"coalesce(c_int_d, c_datetime)", mysql.TypeVarString, charset.CharsetUTF8, 0, 22, types.UnspecifiedLength},
+		{"coalesce(c_date, c_date, c_date)", mysql.TypeDate, charset.CharsetBin, mysql.BinaryFlag, 10, 0},
+		{"coalesce(c_date, c_datetime, c_date)", mysql.TypeDatetime, charset.CharsetBin, mysql.BinaryFlag, 22, 6},
+
+		{"greatest(c_decimal, c_udecimal)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 10, 3},
+		{"greatest(c_decimal, c_int_d)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 14, 3},
+		{"greatest(c_decimal, 1.12345)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 8, 5},
+		{"least(c_decimal, c_int_d)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 14, 3},
+		{"least(c_decimal, 1.12345)", mysql.TypeNewDecimal, charset.CharsetBin, mysql.BinaryFlag, 8, 5},
 
 		{"isnull(c_int_d      )", mysql.TypeLonglong, charset.CharsetBin, mysql.BinaryFlag, 1, 0},
 		{"isnull(c_bigint_d   )", mysql.TypeLonglong, charset.CharsetBin, mysql.BinaryFlag, 1, 0},
@@ -155,6 +155,16 @@ type Client interface {
 	IsRequestTypeSupported(reqType, subType int64) bool
 }
 
+// StoreType represents the type of the storage engine which evaluates the pushed down requests.
+type StoreType uint8
+
+const (
+	// TiKV means the row based storage engine TiKV.
+	TiKV StoreType = iota
+	// TiFlash means the columnar storage engine TiFlash.
+	TiFlash
+)
+
 // ReqTypes.
 const (
 	ReqTypeSelect   = 101
@@ -170,6 +180,7 @@ const (
 	ReqSubTypeSignature  = 10003
 	ReqSubTypeAnalyzeIdx = 10004
 	ReqSubTypeAnalyzeCol = 10005
+	ReqSubTypeSetColumn  = 10006
 )
 
 // Request represents a kv request.
@@ -0,0 +1,52 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// expression metrics.
+var (
+	// ExpressionPushdownPushedCounter records the number of the functions pushed down, by function name.
+	ExpressionPushdownPushedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tidb",
+			Subsystem: "expression",
+			Name:      "pushdown_pushed_total",
+			Help:      "Counter of functions pushed down to the coprocessor.",
+		}, []string{LblType})
+
+	// ExpressionPushdownRemainedCounter records the number of the functions which can't be pushed down, by function name.
+	ExpressionPushdownRemainedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tidb",
+			Subsystem: "expression",
+			Name:      "pushdown_remained_total",
+			Help:      "Counter of functions remained in TiDB.",
+		}, []string{LblType})
+)
+
+var registerExpressionMetricsOnce sync.Once
+
+// RegisterExpressionMetrics registers the expression metrics. It's called lazily on the first use of them,
+// so they are not exported by the programs which never convert expressions.
+func RegisterExpressionMetrics() {
+	registerExpressionMetricsOnce.Do(func() {
+		prometheus.MustRegister(ExpressionPushdownPushedCounter)
+		prometheus.MustRegister(ExpressionPushdownRemainedCounter)
+	})
+}